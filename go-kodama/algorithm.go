@@ -0,0 +1,131 @@
+package kodama
+
+import "fmt"
+
+// Algorithm identifies an algorithm for computing a hierarchical clustering.
+//
+// Every algorithm produces a valid dendrogram for the methods it supports,
+// and all of them take O(n^2) memory for n observations. They differ in
+// running time and in which methods they support:
+//
+//   - AlgorithmMST computes a minimum spanning tree in O(n^2) time. It only
+//     supports single linkage.
+//   - AlgorithmNNChain is the nearest-neighbor chain algorithm, which takes
+//     O(n^2) time. It supports every method except centroid and median
+//     linkage, whose dissimilarities may decrease after a merge.
+//   - AlgorithmGeneric keeps the nearest neighbor of every cluster in a
+//     priority queue. It supports every method, and takes O(n^3) time in
+//     the worst case but O(n^2 log n) time in the best case, which is
+//     typical in practice.
+//
+// See Method.Algorithm for the algorithm that is used by default.
+type Algorithm int
+
+// The available algorithms for computing linkage.
+const (
+	AlgorithmMST Algorithm = iota
+	AlgorithmNNChain
+	AlgorithmGeneric
+)
+
+// Valid returns true if and only if a is one of the algorithms defined by
+// this package.
+func (a Algorithm) Valid() bool {
+	return a >= AlgorithmMST && a <= AlgorithmGeneric
+}
+
+// String returns the name of this algorithm, i.e., "mst", "nn-chain" or
+// "generic". If the algorithm is not valid, then it is formatted as
+// "Algorithm(n)".
+func (a Algorithm) String() string {
+	switch a {
+	case AlgorithmMST:
+		return "mst"
+	case AlgorithmNNChain:
+		return "nn-chain"
+	case AlgorithmGeneric:
+		return "generic"
+	default:
+		return fmt.Sprintf("Algorithm(%d)", int(a))
+	}
+}
+
+// Supports returns true if and only if this algorithm can compute linkage
+// with the given method. It returns false if either a or m is not valid.
+func (a Algorithm) Supports(m Method) bool {
+	if checkMethod(m) != nil {
+		return false
+	}
+	switch a {
+	case AlgorithmMST:
+		return m == MethodSingle
+	case AlgorithmNNChain:
+		return m != MethodCentroid && m != MethodMedian
+	case AlgorithmGeneric:
+		return true
+	default:
+		return false
+	}
+}
+
+// Algorithm returns the algorithm that Linkage64, Linkage32 and every other
+// clustering function use for this method, which is the fastest algorithm
+// that supports it: AlgorithmMST for single linkage, AlgorithmGeneric for
+// centroid and median linkage and AlgorithmNNChain otherwise.
+func (m Method) Algorithm() Algorithm {
+	switch m {
+	case MethodSingle:
+		return AlgorithmMST
+	case MethodCentroid, MethodMedian:
+		return AlgorithmGeneric
+	default:
+		return AlgorithmNNChain
+	}
+}
+
+// LinkageAlgo64 is like Linkage64E, except it computes the clustering with
+// the given algorithm instead of the default algorithm for the method.
+//
+// This is meant for advanced users, e.g., to measure how each algorithm
+// performs on their data. When the given algorithm is the method's default,
+// this is equivalent to Linkage64E. Otherwise, clustering is done by the
+// pure Go implementation of the algorithm, which is slower than the C
+// library. (See the "Pure Go" section of the package documentation.)
+//
+// Every algorithm that supports a method produces the same merge
+// dissimilarities for it, up to floating point rounding, but when there are
+// ties, they may merge clusters in a different order.
+//
+// In addition to the errors returned by Linkage64E, an error is returned if
+// the algorithm is not valid or does not support the method.
+func LinkageAlgo64(
+	condensedDissimilarityMatrix []float64,
+	observations int,
+	method Method,
+	algo Algorithm,
+) (*Dendrogram, error) {
+	if err := checkMethod(method); err != nil {
+		return nil, err
+	}
+	if !algo.Valid() {
+		return nil, fmt.Errorf("unrecognized algorithm: %v", algo)
+	}
+	if !algo.Supports(method) {
+		return nil, fmt.Errorf("%v algorithm does not support %v linkage", algo, method)
+	}
+	if algo == method.Algorithm() {
+		return Linkage64E(condensedDissimilarityMatrix, observations, method)
+	}
+	err := checkLinkageArgs(len(condensedDissimilarityMatrix), observations, method)
+	if err != nil {
+		return nil, err
+	}
+	err = checkFinite(condensedDissimilarityMatrix, observations)
+	if err != nil {
+		return nil, err
+	}
+	steps := goLinkageAlgo(condensedDissimilarityMatrix, observations, method, algo)
+	dend := &Dendrogram{}
+	dend.setSteps(steps, observations)
+	return dend, nil
+}
@@ -0,0 +1,124 @@
+package kodama
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+var allAlgorithms = []Algorithm{AlgorithmMST, AlgorithmNNChain, AlgorithmGeneric}
+
+func TestAlgorithmString(t *testing.T) {
+	tests := []struct {
+		algo     Algorithm
+		expected string
+	}{
+		{AlgorithmMST, "mst"},
+		{AlgorithmNNChain, "nn-chain"},
+		{AlgorithmGeneric, "generic"},
+		{AlgorithmGeneric + 1, "Algorithm(3)"},
+	}
+	for _, test := range tests {
+		if got := test.algo.String(); got != test.expected {
+			t.Fatalf("expected %q, but got %q\n", test.expected, got)
+		}
+	}
+}
+
+func TestMethodAlgorithm(t *testing.T) {
+	for _, method := range allMethods {
+		algo := method.Algorithm()
+		if !algo.Supports(method) {
+			t.Fatalf("%v: default algorithm %v does not support it\n", method, algo)
+		}
+	}
+	if got := MethodWard.Algorithm(); got != AlgorithmNNChain {
+		t.Fatalf("expected %v, but got %v\n", AlgorithmNNChain, got)
+	}
+	if AlgorithmNNChain.Supports(MethodCentroid) || AlgorithmMST.Supports(MethodAverage) {
+		t.Fatal("expected unsupported methods to be rejected")
+	}
+	if AlgorithmGeneric.Supports(MethodFlexible) || (AlgorithmGeneric + 1).Supports(MethodSingle) {
+		t.Fatal("expected invalid arguments to be rejected")
+	}
+}
+
+func TestLinkageAlgo64(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, method := range allMethods {
+		for _, algo := range allAlgorithms {
+			if !algo.Supports(method) {
+				continue
+			}
+			for trial := 0; trial < 20; trial++ {
+				n := rng.Intn(30)
+				dis, err := EuclideanCondensed(randomPoints(rng, n, 3), n, 3)
+				if err != nil {
+					t.Fatal(err)
+				}
+				expected := Linkage64Copy(dis, n, method)
+				got, err := LinkageAlgo64(dis, n, method, algo)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !got.Equal(expected, 1e-9) {
+					t.Fatalf("%v with %v, trial %d: expected %v, but got %v\n",
+						method, algo, trial, expected.Steps(), got.Steps())
+				}
+			}
+		}
+	}
+}
+
+func TestLinkageAlgo64Invalid(t *testing.T) {
+	tests := []struct {
+		matrix []float64
+		method Method
+		algo   Algorithm
+	}{
+		{maCondensedMatrix64, MethodCentroid, AlgorithmNNChain},
+		{maCondensedMatrix64, MethodAverage, AlgorithmMST},
+		{maCondensedMatrix64, MethodAverage, AlgorithmGeneric + 1},
+		{maCondensedMatrix64, MethodFlexible, AlgorithmGeneric},
+		{maCondensedMatrix64[1:], MethodAverage, AlgorithmGeneric},
+		{[]float64{1, 2, math.NaN()}, MethodAverage, AlgorithmGeneric},
+	}
+	for _, test := range tests {
+		n := maObservations
+		if len(test.matrix) == 3 {
+			n = 3
+		}
+		matrix := append([]float64{}, test.matrix...)
+		if _, err := LinkageAlgo64(matrix, n, test.method, test.algo); err == nil {
+			t.Fatalf("%v with %v: expected error\n", test.method, test.algo)
+		}
+	}
+}
+
+func BenchmarkLinkageAlgo64(b *testing.B) {
+	const n = 1000
+	rng := rand.New(rand.NewSource(1))
+	dis, err := EuclideanCondensed(randomPoints(rng, n, 3), n, 3)
+	if err != nil {
+		b.Fatal(err)
+	}
+	matrix := make([]float64, len(dis))
+	for _, method := range allMethods {
+		for _, algo := range allAlgorithms {
+			if !algo.Supports(method) {
+				continue
+			}
+			b.Run(fmt.Sprintf("%v/%v", method, algo), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					b.StopTimer()
+					copy(matrix, dis)
+					b.StartTimer()
+					if _, err := LinkageAlgo64(matrix, n, method, algo); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package kodama
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ASCII returns a textual rendering of this dendrogram's merge tree, which
+// is useful for quickly inspecting clusterings of small data sets.
+//
+// Each cluster is written on its own line, indented beneath the cluster it
+// was merged into. Merged clusters are annotated with the dissimilarity at
+// which they were created, and observations are named by labels. If labels
+// is empty, then observations are named by their index. If labels is not
+// empty and its length is not equal to Observations(), then an error is
+// returned. For example, a dendrogram of three observations renders as:
+//
+//	[3]
+//	+-- 2
+//	`-- [1]
+//	    +-- 0
+//	    `-- 1
+//
+// A partial dendrogram, e.g., as returned by Linkage64Until, renders each
+// of its trees in turn, in the order of Roots. An empty dendrogram renders
+// as the empty string.
+func (dend *Dendrogram) ASCII(labels []string) (string, error) {
+	obs := dend.Observations()
+	if len(labels) > 0 && len(labels) != obs {
+		return "", fmt.Errorf(
+			"expected %d labels, but got %d", obs, len(labels))
+	}
+	if obs == 0 {
+		return "", nil
+	}
+
+	steps := dend.Steps()
+	var buf strings.Builder
+	var write func(label int, prefix, childPrefix string)
+	write = func(label int, prefix, childPrefix string) {
+		buf.WriteString(prefix)
+		if label < obs {
+			if len(labels) == 0 {
+				buf.WriteString(strconv.Itoa(label))
+			} else {
+				buf.WriteString(labels[label])
+			}
+			buf.WriteByte('\n')
+			return
+		}
+		s := steps[label-obs]
+		fmt.Fprintf(&buf, "[%s]\n", strconv.FormatFloat(s.Dissimilarity, 'g', 6, 64))
+		write(s.Cluster1, childPrefix+"+-- ", childPrefix+"|   ")
+		write(s.Cluster2, childPrefix+"`-- ", childPrefix+"    ")
+	}
+	for _, root := range dend.Roots() {
+		write(root, "", "")
+	}
+	return buf.String(), nil
+}
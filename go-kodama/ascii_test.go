@@ -0,0 +1,59 @@
+package kodama
+
+import "testing"
+
+func TestASCII(t *testing.T) {
+	dend := Linkage64([]float64{1, 4, 3}, 3, MethodSingle)
+
+	got, err := dend.ASCII(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "[3]\n" +
+		"+-- 2\n" +
+		"`-- [1]\n" +
+		"    +-- 0\n" +
+		"    `-- 1\n"
+	if got != expected {
+		t.Fatalf("expected\n%s\nbut got\n%s", expected, got)
+	}
+
+	got, err = dend.ASCII([]string{"a", "b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "[3]\n" +
+		"+-- c\n" +
+		"`-- [1]\n" +
+		"    +-- a\n" +
+		"    `-- b\n"
+	if got != expected {
+		t.Fatalf("expected\n%s\nbut got\n%s", expected, got)
+	}
+}
+
+func TestASCIITrivial(t *testing.T) {
+	for obs, expected := range []string{"", "0\n"} {
+		got, err := Linkage64([]float64{}, obs, MethodSingle).ASCII(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != expected {
+			t.Fatalf("expected %q, but got %q\n", expected, got)
+		}
+	}
+	if _, err := maDendrogram().ASCII([]string{"a"}); err == nil {
+		t.Fatal("expected error for mismatched number of labels")
+	}
+}
+
+func TestASCIIForest(t *testing.T) {
+	got, err := maPartialDendrogram().ASCII(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "0\n1\n3\n[5.75716]\n+-- 5\n`-- [3.1238]\n    +-- 2\n    `-- 4\n"
+	if got != expected {
+		t.Fatalf("expected %q, but got %q\n", expected, got)
+	}
+}
@@ -0,0 +1,108 @@
+package kodama
+
+import (
+	"fmt"
+	"math"
+)
+
+// Comparison summarizes how similar two dendrograms of the same observations
+// are.
+type Comparison struct {
+	// K lists the numbers of clusters at which both dendrograms were cut,
+	// which is every k from 2 to Observations() - 1 in ascending order.
+	K []int
+	// FowlkesMallows[i] is the Fowlkes-Mallows index between the flat
+	// clusterings produced by cutting both dendrograms into K[i] clusters
+	// with FlatClustersByCount. This is the B_k statistic from Fowlkes and
+	// Mallows' original paper comparing hierarchical clusterings. It ranges
+	// from 0 to 1, where 1 means the clusterings are identical.
+	FowlkesMallows []float64
+	// DifferingPairs[i] is the number of pairs of observations that are in
+	// the same cluster in exactly one of the two flat clusterings with K[i]
+	// clusters.
+	DifferingPairs []int
+	// CopheneticCorrelation is the Pearson correlation between the
+	// cophenetic distances of the two dendrograms, which compares their
+	// merge heights as well as their shapes. It is NaN if either has no
+	// variance in its cophenetic distances, e.g., with fewer than three
+	// observations.
+	CopheneticCorrelation float64
+}
+
+// CompareDendrograms computes several metrics of agreement between two
+// dendrograms of the same observations, e.g., as produced by clustering the
+// same dissimilarities with different methods. See Comparison for the
+// metrics that are computed.
+//
+// This takes time quadratic in the number of observations. An error is
+// returned if the dendrograms do not have the same number of observations.
+func CompareDendrograms(a, b *Dendrogram) (Comparison, error) {
+	obs := a.Observations()
+	if b.Observations() != obs {
+		return Comparison{}, fmt.Errorf(
+			"expected dendrograms with the same number of observations, "+
+				"but got %d and %d", obs, b.Observations())
+	}
+	var cmp Comparison
+	for k := 2; k < obs; k++ {
+		counts := countPairs(a.FlatClustersByCount(k), b.FlatClustersByCount(k))
+		cmp.K = append(cmp.K, k)
+		cmp.FowlkesMallows = append(cmp.FowlkesMallows, counts.fowlkesMallows())
+		cmp.DifferingPairs = append(cmp.DifferingPairs, int(counts.inA+counts.inB-2*counts.inBoth))
+	}
+	cmp.CopheneticCorrelation = math.NaN()
+	if obs >= 2 {
+		cmp.CopheneticCorrelation = pearson(a.Cophenetic(), b.Cophenetic())
+	}
+	return cmp, nil
+}
+
+// pairCounts counts the pairs of observations that are in the same cluster
+// in each of two flat clusterings of the same observations.
+type pairCounts struct {
+	// inBoth is the number of pairs in the same cluster in both
+	// clusterings, and inA and inB are the number of pairs in the same
+	// cluster in each clustering, respectively.
+	inBoth, inA, inB float64
+	// total is the total number of pairs of observations.
+	total float64
+}
+
+// countPairs builds the contingency table of the given flat clusterings,
+// which must have the same length, and counts the pairs of observations
+// that are in the same cluster. Labels may be any integers.
+func countPairs(labelsA, labelsB []int) pairCounts {
+	choose2 := func(n int) float64 {
+		return float64(n) * float64(n-1) / 2
+	}
+	table := make(map[[2]int]int)
+	sizesA := make(map[int]int)
+	sizesB := make(map[int]int)
+	for i := range labelsA {
+		table[[2]int{labelsA[i], labelsB[i]}]++
+		sizesA[labelsA[i]]++
+		sizesB[labelsB[i]]++
+	}
+	var counts pairCounts
+	for _, n := range table {
+		counts.inBoth += choose2(n)
+	}
+	for _, n := range sizesA {
+		counts.inA += choose2(n)
+	}
+	for _, n := range sizesB {
+		counts.inB += choose2(n)
+	}
+	counts.total = choose2(len(labelsA))
+	return counts
+}
+
+// fowlkesMallows returns the geometric mean of the precision and recall of
+// the pairs in the same cluster. Following common practice, it is 0 when no
+// pair is in the same cluster in both clusterings.
+func (c pairCounts) fowlkesMallows() float64 {
+	if c.inBoth == 0 {
+		return 0
+	}
+	return math.Sqrt(c.inBoth/c.inA) * math.Sqrt(c.inBoth/c.inB)
+}
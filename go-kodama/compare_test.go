@@ -0,0 +1,96 @@
+package kodama
+
+import (
+	"math"
+	"testing"
+)
+
+// bruteFowlkesMallows computes the Fowlkes-Mallows index and the number of
+// differing pairs by checking every pair of observations.
+func bruteFowlkesMallows(labelsA, labelsB []int) (float64, int) {
+	var both, inA, inB, differing int
+	for i := range labelsA {
+		for j := i + 1; j < len(labelsA); j++ {
+			sameA, sameB := labelsA[i] == labelsA[j], labelsB[i] == labelsB[j]
+			if sameA {
+				inA++
+			}
+			if sameB {
+				inB++
+			}
+			if sameA && sameB {
+				both++
+			}
+			if sameA != sameB {
+				differing++
+			}
+		}
+	}
+	if both == 0 {
+		return 0, differing
+	}
+	return float64(both) / math.Sqrt(float64(inA)*float64(inB)), differing
+}
+
+func TestCompareDendrograms(t *testing.T) {
+	average := maDendrogram()
+	single := Linkage64Copy(maCondensedMatrix64, maObservations, MethodSingle)
+	cmp, err := CompareDendrograms(average, single)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cmp.K) != maObservations-2 {
+		t.Fatalf("expected %d cuts, but got %v\n", maObservations-2, cmp.K)
+	}
+	for i, k := range cmp.K {
+		if k != i+2 {
+			t.Fatalf("expected k=%d, but got %d\n", i+2, k)
+		}
+		fm, differing := bruteFowlkesMallows(
+			average.FlatClustersByCount(k), single.FlatClustersByCount(k))
+		if math.Abs(cmp.FowlkesMallows[i]-fm) > 1e-12 {
+			t.Fatalf("k=%d: expected Fowlkes-Mallows %v, but got %v\n",
+				k, fm, cmp.FowlkesMallows[i])
+		}
+		if cmp.DifferingPairs[i] != differing {
+			t.Fatalf("k=%d: expected %d differing pairs, but got %d\n",
+				k, differing, cmp.DifferingPairs[i])
+		}
+	}
+	expected := pearson(average.Cophenetic(), single.Cophenetic())
+	if cmp.CopheneticCorrelation != expected {
+		t.Fatalf("expected cophenetic correlation %v, but got %v\n",
+			expected, cmp.CopheneticCorrelation)
+	}
+}
+
+func TestCompareDendrogramsSelf(t *testing.T) {
+	dend := maDendrogram()
+	cmp, err := CompareDendrograms(dend, dend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range cmp.K {
+		if cmp.FowlkesMallows[i] != 1 || cmp.DifferingPairs[i] != 0 {
+			t.Fatalf("k=%d: expected identical clusterings, but got %v and %d\n",
+				cmp.K[i], cmp.FowlkesMallows[i], cmp.DifferingPairs[i])
+		}
+	}
+	if math.Abs(cmp.CopheneticCorrelation-1) > 1e-12 {
+		t.Fatalf("expected cophenetic correlation 1, but got %v\n", cmp.CopheneticCorrelation)
+	}
+}
+
+func TestCompareDendrogramsMismatch(t *testing.T) {
+	small := Linkage64([]float64{1}, 2, MethodAverage)
+	if _, err := CompareDendrograms(maDendrogram(), small); err == nil {
+		t.Fatalf("expected error for different numbers of observations\n")
+	}
+	cmp, err := CompareDendrograms(small, small)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cmp.K) != 0 || !math.IsNaN(cmp.CopheneticCorrelation) {
+		t.Fatalf("expected no cuts and NaN correlation, but got %+v\n", cmp)
+	}
+}
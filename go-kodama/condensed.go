@@ -0,0 +1,223 @@
+package kodama
+
+import (
+	"fmt"
+	"math"
+)
+
+// Condensed is a condensed pairwise dissimilarity matrix along with the
+// number of observations it describes.
+//
+// Data is laid out as documented on Linkage64, and its length must be
+// Observations-choose-2. Values should be created with NewCondensed, which
+// checks this once, so that the length need not be checked again by each
+// function that uses the matrix.
+type Condensed struct {
+	Data         []float64
+	Observations int
+}
+
+// NewCondensed wraps the given condensed matrix for the given number of
+// observations. The data is not copied. An error is returned if the number
+// of observations is negative or the length of data is not consistent with
+// it.
+func NewCondensed(data []float64, observations int) (Condensed, error) {
+	if err := checkMatrixLen(len(data), observations); err != nil {
+		return Condensed{}, err
+	}
+	return Condensed{Data: data, Observations: observations}, nil
+}
+
+// At returns the dissimilarity between observations i and j. The order of i
+// and j does not matter, and the dissimilarity between an observation and
+// itself is always 0. This panics if either is not in the range
+// [0, Observations).
+func (c Condensed) At(i, j int) float64 {
+	if i == j && i >= 0 && i < c.Observations {
+		return 0
+	}
+	return c.Data[CondensedIndex(c.Observations, i, j)]
+}
+
+// Set sets the dissimilarity between observations i and j to v. The order of
+// i and j does not matter. This panics if i and j are equal or if either is
+// not in the range [0, Observations).
+func (c Condensed) Set(i, j int, v float64) {
+	c.Data[CondensedIndex(c.Observations, i, j)] = v
+}
+
+// CondensedIndex returns the index into a condensed pairwise dissimilarity
+// matrix for the given number of observations that corresponds to the
+// dissimilarity between observations i and j.
+//
+// The order of i and j does not matter. This function panics if i and j are
+// equal or if either is not in the range [0, observations).
+func CondensedIndex(observations, i, j int) int {
+	if i < 0 || i >= observations || j < 0 || j >= observations {
+		panic(fmt.Errorf(
+			"expected observations in range [0, %d), but got (%d, %d)",
+			observations, i, j))
+	}
+	if i == j {
+		panic(fmt.Errorf(
+			"condensed matrix has no entry for the pair (%d, %d)", i, j))
+	}
+	if i > j {
+		i, j = j, i
+	}
+	return condensedIndex(observations, i, j)
+}
+
+// CondensedPair is the inverse of CondensedIndex. It returns the pair of
+// observations, with i < j, whose dissimilarity is stored at the given index
+// of a condensed pairwise dissimilarity matrix for the given number of
+// observations.
+//
+// This function panics if index is not in the range
+// [0, observations-choose-2).
+func CondensedPair(observations, index int) (i, j int) {
+	if observations < 2 || index < 0 || index >= (observations*(observations-1))/2 {
+		panic(fmt.Errorf(
+			"condensed index %d out of range for %d observations",
+			index, observations))
+	}
+	return condensedPair(observations, index)
+}
+
+// condensedIndex converts the given row and column of a pairwise
+// dissimilarity matrix into an index into the corresponding condensed
+// matrix for the given number of observations.
+//
+// The row must be less than the column, and the column must be less than
+// the number of observations.
+func condensedIndex(observations, row, column int) int {
+	// This is the same formulation used by the Rust library. It's easy to
+	// derive from the more natural
+	//
+	//     (observations * row) + column - ((row * (row + 1)) / 2) - 1 - row
+	//
+	// through simple algebraic transformations.
+	return ((2*observations-row-3)*row)/2 + column - 1
+}
+
+// condensedPair is the inverse of condensedIndex. It converts the given index
+// into a condensed matrix for the given number of observations into the row
+// and column of the corresponding pairwise dissimilarity matrix.
+//
+// The index must be less than observations-choose-2.
+func condensedPair(observations, index int) (row, column int) {
+	// Row r of the upper triangle has observations - r - 1 entries, so
+	// skip over whole rows until the index falls within one.
+	for rowLen := observations - 1; index >= rowLen; rowLen-- {
+		index -= rowLen
+		row++
+	}
+	return row, row + index + 1
+}
+
+// SquareMatrix squares every dissimilarity in the given matrix in place.
+//
+// This works on any matrix, but is typically used to convert a condensed
+// matrix of Euclidean distances into squared Euclidean distances for
+// methods that do not square their input themselves. Note that MethodWard,
+// MethodCentroid and MethodMedian already square their input, so they
+// should be given the unsquared distances. (See the documentation for
+// Method for details.)
+func SquareMatrix(matrix []float64) {
+	for i, x := range matrix {
+		matrix[i] = x * x
+	}
+}
+
+// symmetryTolerance is the relative tolerance used when checking that a
+// square dissimilarity matrix is symmetric and has a zero diagonal.
+const symmetryTolerance = 1e-9
+
+// approxEqual reports whether a and b are equal within symmetryTolerance,
+// relative to the larger of their magnitudes (or 1, if both are small).
+// Equal infinities are equal, and NaN is only equal to NaN, so that a
+// matrix with infinite or NaN dissimilarities can still be symmetric.
+func approxEqual(a, b float64) bool {
+	if a == b || (math.IsNaN(a) && math.IsNaN(b)) {
+		return true
+	}
+	if math.IsNaN(a) || math.IsNaN(b) || math.IsInf(a, 0) || math.IsInf(b, 0) {
+		return false
+	}
+	scale := math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+	return math.Abs(a-b) <= symmetryTolerance*scale
+}
+
+// ToSquare converts the given condensed dissimilarity matrix into a newly
+// allocated square one. It is the inverse of ToCondensed.
+//
+// The square matrix has observations*observations elements in row-major
+// order, such that the dissimilarity between observations i and j is at
+// index i*observations+j. It is symmetric, so that is the same as the
+// element at index j*observations+i, and its diagonal is zero. For i < j,
+// both are the element of the condensed matrix at CondensedIndex(i, j).
+//
+// This panics if the number of observations is negative or the length of
+// the condensed matrix is not consistent with it.
+func ToSquare(condensed []float64, observations int) []float64 {
+	if err := checkMatrixLen(len(condensed), observations); err != nil {
+		panic(err)
+	}
+	square := make([]float64, observations*observations)
+	k := 0
+	for i := 0; i < observations; i++ {
+		for j := i + 1; j < observations; j++ {
+			square[i*observations+j] = condensed[k]
+			square[j*observations+i] = condensed[k]
+			k++
+		}
+	}
+	return square
+}
+
+// ToCondensed converts the given square dissimilarity matrix into a newly
+// allocated condensed one, suitable for passing to Linkage64. It is the
+// inverse of ToSquare, and the square matrix must be laid out as documented
+// there.
+//
+// The condensed matrix is the upper triangle of the square matrix, not
+// including the diagonal, in row-major order. That is, it contains the
+// elements at (0, 1), (0, 2), ..., (0, n-1), (1, 2), ..., (n-2, n-1), which
+// is the layout documented on Linkage64.
+//
+// An error is returned if the number of observations is negative, if the
+// length of the square matrix is not observations*observations or if the
+// square matrix is not symmetric with zeros along its diagonal, up to a
+// small relative tolerance.
+func ToCondensed(square []float64, observations int) ([]float64, error) {
+	if observations < 0 {
+		return nil, fmt.Errorf(
+			"expected non-negative number of observations, but got %d",
+			observations)
+	}
+	if len(square) != observations*observations {
+		return nil, fmt.Errorf(
+			"expected square matrix of length %d, but got %d",
+			observations*observations, len(square))
+	}
+	condensed := make([]float64, (observations*(observations-1))/2)
+	k := 0
+	for i := 0; i < observations; i++ {
+		if d := square[i*observations+i]; !approxEqual(d, 0) {
+			return nil, fmt.Errorf(
+				"expected zero diagonal, but element (%d,%d) is %v", i, i, d)
+		}
+		for j := i + 1; j < observations; j++ {
+			upper := square[i*observations+j]
+			lower := square[j*observations+i]
+			if !approxEqual(upper, lower) {
+				return nil, fmt.Errorf(
+					"matrix is not symmetric: element (%d,%d) is %v, but element (%d,%d) is %v",
+					i, j, upper, j, i, lower)
+			}
+			condensed[k] = upper
+			k++
+		}
+	}
+	return condensed, nil
+}
@@ -0,0 +1,170 @@
+package kodama
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestCondensedIndexRoundTrip(t *testing.T) {
+	for obs := 2; obs < 10; obs++ {
+		index := 0
+		for row := 0; row < obs; row++ {
+			for column := row + 1; column < obs; column++ {
+				if got := condensedIndex(obs, row, column); got != index {
+					t.Fatalf("(%d,%d) with %d observations: expected index %d, but got %d\n",
+						row, column, obs, index, got)
+				}
+				r, c := condensedPair(obs, index)
+				if r != row || c != column {
+					t.Fatalf("index %d with %d observations: expected (%d,%d), but got (%d,%d)\n",
+						index, obs, row, column, r, c)
+				}
+				index++
+			}
+		}
+	}
+}
+
+func TestCondensedIndex(t *testing.T) {
+	// (marlborough, southborough) from maCondensedMatrix64.
+	if got := CondensedIndex(maObservations, 2, 4); got != 10 {
+		t.Fatalf("expected index 10, but got %d\n", got)
+	}
+	if got := CondensedIndex(maObservations, 4, 2); got != 10 {
+		t.Fatalf("expected index 10, but got %d\n", got)
+	}
+	if i, j := CondensedPair(maObservations, 10); i != 2 || j != 4 {
+		t.Fatalf("expected (2, 4), but got (%d, %d)\n", i, j)
+	}
+}
+
+func TestCondensedIndexPanics(t *testing.T) {
+	tests := []func(){
+		func() { CondensedIndex(3, 1, 1) },
+		func() { CondensedIndex(3, -1, 1) },
+		func() { CondensedIndex(3, 0, 3) },
+		func() { CondensedPair(3, 3) },
+		func() { CondensedPair(3, -1) },
+		func() { CondensedPair(1, 0) },
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected panic")
+				}
+			}()
+			test()
+		})
+	}
+}
+
+func TestSquareMatrix(t *testing.T) {
+	matrix := []float64{1, -2, 0.5}
+	SquareMatrix(matrix)
+	assertFloatsApproxEq(t, matrix, []float64{1, 4, 0.25})
+}
+
+func TestToSquare(t *testing.T) {
+	got := ToSquare([]float64{1, 2, 3}, 3)
+	expected := []float64{
+		0, 1, 2,
+		1, 0, 3,
+		2, 3, 0,
+	}
+	assertFloatsApproxEq(t, got, expected)
+	if got := ToSquare(nil, 0); len(got) != 0 {
+		t.Fatalf("expected empty matrix, but got %v\n", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic for mismatched matrix length")
+		}
+	}()
+	ToSquare([]float64{1, 2}, 3)
+}
+
+func TestToCondensedRoundTrip(t *testing.T) {
+	square := ToSquare(maCondensedMatrix64, maObservations)
+	got, err := ToCondensed(square, maObservations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFloatsApproxEq(t, got, maCondensedMatrix64)
+
+	// Infinite and NaN dissimilarities survive the round trip too.
+	condensed := []float64{1, math.Inf(1), math.NaN()}
+	got, err = ToCondensed(ToSquare(condensed, 3), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != 1 || !math.IsInf(got[1], 1) || !math.IsNaN(got[2]) {
+		t.Fatalf("expected %v, but got %v\n", condensed, got)
+	}
+}
+
+func TestToCondensedInvalid(t *testing.T) {
+	tests := []struct {
+		square       []float64
+		observations int
+	}{
+		{[]float64{0, 1, 1}, 2},
+		{[]float64{0, 1, 2, 0}, 2},
+		{[]float64{1, 1, 1, 0}, 2},
+		{[]float64{0, math.Inf(1), 1, 0}, 2},
+		{[]float64{0, math.Inf(1), math.Inf(-1), 0}, 2},
+		{[]float64{0, math.NaN(), 1, 0}, 2},
+		{[]float64{math.NaN(), 1, 1, 0}, 2},
+		{[]float64{}, -1},
+	}
+	for _, test := range tests {
+		if _, err := ToCondensed(test.square, test.observations); err == nil {
+			t.Fatalf("%v: expected error\n", test.square)
+		}
+	}
+}
+
+func TestCondensed(t *testing.T) {
+	c, err := NewCondensed(make([]float64, 6), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set(2, 1, 5)
+	c.Set(0, 3, 7)
+	if got := c.At(1, 2); got != 5 {
+		t.Fatalf("expected 5, but got %v\n", got)
+	}
+	if got := c.At(3, 0); got != 7 {
+		t.Fatalf("expected 7, but got %v\n", got)
+	}
+	if got := c.At(2, 2); got != 0 {
+		t.Fatalf("expected 0 on the diagonal, but got %v\n", got)
+	}
+	if got := c.Data[CondensedIndex(4, 1, 2)]; got != 5 {
+		t.Fatalf("expected Set to write through to Data, but got %v\n", got)
+	}
+
+	if _, err := NewCondensed(make([]float64, 5), 4); err == nil {
+		t.Fatalf("expected error for wrong length\n")
+	}
+	if _, err := NewCondensed(nil, -1); err == nil {
+		t.Fatalf("expected error for negative observations\n")
+	}
+	tests := []func(){
+		func() { c.At(0, 4) },
+		func() { c.At(-1, -1) },
+		func() { c.Set(1, 1, 0) },
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected panic")
+				}
+			}()
+			test()
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package kodama
+
+import "fmt"
+
+// ConsensusMatrix returns a condensed matrix of how often each pair of
+// observations is clustered together across several flat clusterings, for
+// consensus clustering.
+//
+// Each label set assigns a cluster label to each observation, e.g., as
+// returned by FlatClusters for one cut of one dendrogram. Two observations
+// are in the same cluster of a label set when they have equal labels, and
+// the element of the returned matrix for the pair is the fraction of label
+// sets in which they are, which is in [0, 1]. The matrix is in the same
+// condensed form as the input to Linkage64, so that 1 - x for each element
+// x is a dissimilarity that can be clustered to find a consensus
+// clustering.
+//
+// An error is returned if there are no label sets, if the number of
+// observations is negative or if any label set does not have exactly one
+// label per observation.
+func ConsensusMatrix(labelSets [][]int, observations int) ([]float64, error) {
+	if observations < 0 {
+		return nil, fmt.Errorf(
+			"expected non-negative number of observations, but got %d",
+			observations)
+	}
+	if len(labelSets) == 0 {
+		return nil, fmt.Errorf("expected at least one label set, but got none")
+	}
+	for i, labels := range labelSets {
+		if len(labels) != observations {
+			return nil, fmt.Errorf(
+				"expected %d labels in label set %d, but got %d",
+				observations, i, len(labels))
+		}
+	}
+
+	matrix := make([]float64, (observations*(observations-1))/2)
+	for _, labels := range labelSets {
+		k := 0
+		for i := 0; i < observations; i++ {
+			for j := i + 1; j < observations; j++ {
+				if labels[i] == labels[j] {
+					matrix[k]++
+				}
+				k++
+			}
+		}
+	}
+	for k := range matrix {
+		matrix[k] /= float64(len(labelSets))
+	}
+	return matrix, nil
+}
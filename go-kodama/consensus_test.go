@@ -0,0 +1,52 @@
+package kodama
+
+import "testing"
+
+func TestConsensusMatrix(t *testing.T) {
+	labelSets := [][]int{
+		{0, 0, 1, 1},
+		{0, 0, 0, 1},
+		{5, 7, 7, 7},
+		{0, 0, 1, 1},
+	}
+	got, err := ConsensusMatrix(labelSets, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Pairs in condensed order: (0,1), (0,2), (0,3), (1,2), (1,3), (2,3).
+	expected := []float64{0.75, 0.25, 0, 0.5, 0.25, 0.75}
+	assertFloatsApproxEq(t, got, expected)
+}
+
+func TestConsensusMatrixLinkage(t *testing.T) {
+	// Clustering the consensus of a single cut recovers that cut.
+	dend := maDendrogram()
+	labels := dend.FlatClustersByCount(2)
+	consensus, err := ConsensusMatrix([][]int{labels}, maObservations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, x := range consensus {
+		consensus[i] = 1 - x
+	}
+	got := Linkage64(consensus, maObservations, MethodAverage).FlatClustersByCount(2)
+	for i := 0; i < maObservations; i++ {
+		for j := i + 1; j < maObservations; j++ {
+			if (labels[i] == labels[j]) != (got[i] == got[j]) {
+				t.Fatalf("expected %v, but got %v\n", labels, got)
+			}
+		}
+	}
+}
+
+func TestConsensusMatrixInvalid(t *testing.T) {
+	if _, err := ConsensusMatrix(nil, 3); err == nil {
+		t.Fatal("expected error for no label sets")
+	}
+	if _, err := ConsensusMatrix([][]int{{0, 1, 2}, {0, 1}}, 3); err == nil {
+		t.Fatal("expected error for mismatched label set length")
+	}
+	if _, err := ConsensusMatrix([][]int{{}}, -1); err == nil {
+		t.Fatal("expected error for negative observations")
+	}
+}
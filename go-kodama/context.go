@@ -0,0 +1,48 @@
+package kodama
+
+import "context"
+
+// Linkage64Context is like Linkage64E, except it stops waiting for the
+// clustering to finish when the given context is done, in which case it
+// returns ctx.Err().
+//
+// Cancellation is coarse grained. The context is checked before and after
+// the input is validated, and then again while waiting for clustering to
+// complete. The C library provides no way to interrupt clustering once it
+// has started, so when the context is done during clustering, this function
+// returns immediately but the computation continues in the background until
+// it completes, at which point its result is freed. In that case, the given
+// matrix may still be read and mutated by the abandoned computation after
+// this function returns, so callers must not reuse it.
+func Linkage64Context(
+	ctx context.Context,
+	condensedDissimilarityMatrix []float64,
+	observations int,
+	method Method,
+) (*Dendrogram, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	err := checkLinkageArgs(len(condensedDissimilarityMatrix), observations, method)
+	if err != nil {
+		return nil, err
+	}
+	err = checkFinite(condensedDissimilarityMatrix, observations)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	done := make(chan *Dendrogram, 1)
+	go func() {
+		done <- linkage64(condensedDissimilarityMatrix, observations, method)
+	}()
+	select {
+	case dend := <-done:
+		return dend, nil
+	case <-ctx.Done():
+		go func() { (<-done).Close() }()
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,35 @@
+package kodama
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLinkage64Context(t *testing.T) {
+	dis := make([]float64, len(maCondensedMatrix64))
+	copy(dis, maCondensedMatrix64)
+
+	dend, err := Linkage64Context(context.Background(), dis, maObservations, MethodAverage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := dend.Steps()
+	for i := range steps {
+		assertStepApproxEq(t, i, steps[i], maSteps[i])
+	}
+}
+
+func TestLinkage64ContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	dis := make([]float64, len(maCondensedMatrix64))
+	copy(dis, maCondensedMatrix64)
+	dend, err := Linkage64Context(ctx, dis, maObservations, MethodAverage)
+	if err != context.Canceled {
+		t.Fatalf("expected %v, but got %v\n", context.Canceled, err)
+	}
+	if dend != nil {
+		t.Fatal("expected nil dendrogram")
+	}
+}
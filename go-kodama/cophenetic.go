@@ -0,0 +1,160 @@
+package kodama
+
+import (
+	"fmt"
+	"math"
+)
+
+// Cophenetic returns the cophenetic distances between every pair of
+// observations in this dendrogram.
+//
+// The cophenetic distance between two observations is the dissimilarity of
+// the step at which they are first merged into the same cluster. These
+// dissimilarities are exactly the Dissimilarity values of the steps
+// returned by Steps().
+//
+// The distances are returned as a condensed pairwise dissimilarity matrix in
+// the same layout consumed by Linkage64. Namely, the returned slice has
+// length observations-choose-2, where the distance between observations a
+// and b, with a < b, is at index ((2*observations - a - 3) * a / 2) + b - 1.
+//
+// In a partial dendrogram, e.g., as returned by Linkage64Until, the
+// distance between two observations in different trees is +Inf, since they
+// are never merged. If this dendrogram has fewer than two observations, then
+// an empty slice is returned.
+func (dend *Dendrogram) Cophenetic() []float64 {
+	obs := dend.Observations()
+	if obs < 2 {
+		return []float64{}
+	}
+	cophenetic := make([]float64, (obs*(obs-1))/2)
+	if dend.NumTrees() > 1 {
+		for i := range cophenetic {
+			cophenetic[i] = math.Inf(1)
+		}
+	}
+	// members[label] is the list of observations in the cluster with the
+	// given label. Leaves are initialized lazily.
+	members := make([][]int, obs+dend.Len())
+	clusterMembers := func(label int) []int {
+		if label < obs {
+			return []int{label}
+		}
+		return members[label]
+	}
+	for i, s := range dend.Steps() {
+		members1 := clusterMembers(s.Cluster1)
+		members2 := clusterMembers(s.Cluster2)
+		for _, a := range members1 {
+			for _, b := range members2 {
+				row, column := a, b
+				if row > column {
+					row, column = column, row
+				}
+				cophenetic[condensedIndex(obs, row, column)] = s.Dissimilarity
+			}
+		}
+		merged := make([]int, 0, len(members1)+len(members2))
+		merged = append(merged, members1...)
+		members[obs+i] = append(merged, members2...)
+		// The children will never be referenced again, so let the
+		// garbage collector reclaim them.
+		members[s.Cluster1], members[s.Cluster2] = nil, nil
+	}
+	return cophenetic
+}
+
+// MergeHeight returns the cophenetic distance between observations i and j,
+// which is the dissimilarity of the step at which they are first merged into
+// the same cluster. If i and j are the same observation, then 0 is returned.
+// If they are in different trees of a partial dendrogram, e.g., as returned
+// by Linkage64Until, then they are never merged and +Inf is returned.
+//
+// This is equivalent to looking up the pair in the result of Cophenetic, but
+// only takes time linear in the number of steps and does not allocate the
+// full cophenetic matrix. If i or j is not in the range [0,
+// Observations()), then this method panics.
+func (dend *Dendrogram) MergeHeight(i, j int) float64 {
+	obs := dend.Observations()
+	if i < 0 || i >= obs || j < 0 || j >= obs {
+		panic(fmt.Errorf(
+			"expected observations in range [0, %d), but got (%d, %d)",
+			obs, i, j))
+	}
+	if i == j {
+		return 0
+	}
+	steps := dend.Steps()
+	// parents[label] is the index of the step that merges the cluster with
+	// the given label, or -1 if it is the root of its tree.
+	parents := make([]int, obs+len(steps))
+	for label := range parents {
+		parents[label] = -1
+	}
+	for k, s := range steps {
+		parents[s.Cluster1], parents[s.Cluster2] = k, k
+	}
+	// Every step merges the clusters created by earlier steps, so the
+	// first step on j's path to its root that is also on i's path is their
+	// lowest common ancestor. If there is none, then they are in different
+	// trees.
+	onPath := make([]bool, len(steps))
+	for label := i; parents[label] >= 0; label = obs + parents[label] {
+		onPath[parents[label]] = true
+	}
+	for label := j; parents[label] >= 0; label = obs + parents[label] {
+		if onPath[parents[label]] {
+			return steps[parents[label]].Dissimilarity
+		}
+	}
+	return math.Inf(1)
+}
+
+// CopheneticCorrelation returns the cophenetic correlation coefficient of
+// this dendrogram with respect to the given condensed dissimilarity matrix.
+//
+// The coefficient is the Pearson correlation between the original
+// dissimilarities and the cophenetic distances returned by Cophenetic. The
+// closer it is to 1, the more faithfully the dendrogram preserves the
+// original pairwise dissimilarities.
+//
+// The given matrix should be the one used to build this dendrogram, before
+// it was mutated by clustering. If its length is not observations-choose-2,
+// then an error is returned. If either the original dissimilarities or the
+// cophenetic distances have no variance (for example, when there are fewer
+// than three observations), then the correlation is undefined and NaN is
+// returned.
+func (dend *Dendrogram) CopheneticCorrelation(original []float64) (float64, error) {
+	obs := dend.Observations()
+	expectedLen := (obs * (obs - 1)) / 2
+	if len(original) != expectedLen {
+		return 0, fmt.Errorf(
+			"expected dissimilarity matrix of length %d, but got %d",
+			expectedLen, len(original))
+	}
+	return pearson(original, dend.Cophenetic()), nil
+}
+
+// pearson returns the Pearson correlation coefficient between xs and ys,
+// which must have the same length.
+func pearson(xs, ys []float64) float64 {
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= float64(len(xs))
+	meanY /= float64(len(ys))
+
+	var cov, varX, varY float64
+	for i := range xs {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return math.NaN()
+	}
+	return cov / math.Sqrt(varX*varY)
+}
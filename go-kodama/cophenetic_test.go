@@ -0,0 +1,127 @@
+package kodama
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCophenetic(t *testing.T) {
+	// Pairs are listed in condensed order, exactly as in
+	// maCondensedMatrix64.
+	expected := []float64{
+		25.589444117482433, /* fitchburg, framingham */
+		25.589444117482433, /* fitchburg, marlborough */
+		25.589444117482433, /* fitchburg, northbridge */
+		25.589444117482433, /* fitchburg, southborough */
+		25.589444117482433, /* fitchburg, westborough */
+		8.1392602685723,    /* framingham, marlborough */
+		12.483148228609206, /* framingham, northbridge */
+		8.1392602685723,    /* framingham, southborough */
+		8.1392602685723,    /* framingham, westborough */
+		12.483148228609206, /* marlborough, northbridge */
+		3.1237967760688776, /* marlborough, southborough */
+		5.757158112027513,  /* marlborough, westborough */
+		12.483148228609206, /* northbridge, southborough */
+		12.483148228609206, /* northbridge, westborough */
+		5.757158112027513,  /* southborough, westborough */
+	}
+	got := maDendrogram().Cophenetic()
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d distances, but got %d\n", len(expected), len(got))
+	}
+	for i := range got {
+		if math.Abs(got[i]-expected[i]) > 0.000001 {
+			t.Fatalf("index %d: expected %f, but got %f\n", i, expected[i], got[i])
+		}
+	}
+}
+
+func TestCopheneticEmpty(t *testing.T) {
+	for _, obs := range []int{0, 1} {
+		dend := Linkage64([]float64{}, obs, MethodAverage)
+		if got := dend.Cophenetic(); got == nil || len(got) != 0 {
+			t.Fatalf("expected empty slice, but got %#v\n", got)
+		}
+	}
+}
+
+func TestMergeHeight(t *testing.T) {
+	dend := maDendrogram()
+	cophenetic := dend.Cophenetic()
+	for i := 0; i < maObservations; i++ {
+		if got := dend.MergeHeight(i, i); got != 0 {
+			t.Fatalf("expected 0 for (%d, %d), but got %v\n", i, i, got)
+		}
+		for j := 0; j < maObservations; j++ {
+			if i == j {
+				continue
+			}
+			expected := cophenetic[CondensedIndex(maObservations, i, j)]
+			if got := dend.MergeHeight(i, j); got != expected {
+				t.Fatalf("expected %v for (%d, %d), but got %v\n", expected, i, j, got)
+			}
+		}
+	}
+
+	for _, pair := range [][2]int{{-1, 0}, {0, maObservations}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expected panic for %v\n", pair)
+				}
+			}()
+			dend.MergeHeight(pair[0], pair[1])
+		}()
+	}
+}
+
+func TestMergeHeightForest(t *testing.T) {
+	full := maDendrogram()
+	dis := append([]float64{}, maCondensedMatrix64...)
+	dend, err := Linkage64Until(dis, maObservations, MethodAverage, func(s Step) bool {
+		return s.Size > 3
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dend.NumTrees() < 2 {
+		t.Fatalf("expected a forest, but got %v\n", dend.Steps())
+	}
+	labels := dend.FlatClusters(math.Inf(1))
+	cophenetic := dend.Cophenetic()
+	for i := 0; i < maObservations; i++ {
+		for j := i + 1; j < maObservations; j++ {
+			expected := math.Inf(1)
+			if labels[i] == labels[j] {
+				expected = full.MergeHeight(i, j)
+			}
+			if got := dend.MergeHeight(i, j); got != expected {
+				t.Fatalf("expected %v for (%d, %d), but got %v\n", expected, i, j, got)
+			}
+			if got := cophenetic[CondensedIndex(maObservations, i, j)]; got != expected {
+				t.Fatalf("expected cophenetic distance %v for (%d, %d), but got %v\n",
+					expected, i, j, got)
+			}
+		}
+	}
+}
+
+func TestCopheneticCorrelation(t *testing.T) {
+	dend := maDendrogram()
+	got, err := dend.CopheneticCorrelation(maCondensedMatrix64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Computed independently from the cophenetic distances in TestCophenetic.
+	expected := 0.9531472961923644
+	if math.Abs(got-expected) > 0.000001 {
+		t.Fatalf("expected %f, but got %f\n", expected, got)
+	}
+}
+
+func TestCopheneticCorrelationBadLength(t *testing.T) {
+	dend := maDendrogram()
+	if _, err := dend.CopheneticCorrelation(maCondensedMatrix64[1:]); err == nil {
+		t.Fatal("expected error for mismatched matrix length")
+	}
+}
@@ -0,0 +1,116 @@
+package kodama
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ReadCSVCondensed reads a condensed dissimilarity matrix for the given
+// number of observations from CSV records of the form "i,j,distance".
+//
+// Each record gives the dissimilarity between observations i and j, which
+// are zero-based and may be in either order. Fields may be surrounded by
+// whitespace, and if none of the fields of the first record are numbers,
+// then it is treated as a header and skipped. Records are parsed as they
+// are read, so memory use is proportional to the size of the returned
+// matrix rather than the size of the input.
+//
+// Every pair of distinct observations must be given exactly once. An error
+// that includes the offending line number is returned if a record does not
+// have three fields, if a field cannot be parsed, if an observation is out
+// of range, if a record pairs an observation with itself or if a pair is
+// given more than once. An error is also returned if any pair is never
+// given (see ReadCSVCondensedMissing to fill in missing pairs instead), or
+// if reading from r fails.
+func ReadCSVCondensed(r io.Reader, observations int) ([]float64, error) {
+	return readCSVCondensed(r, observations, nil)
+}
+
+// ReadCSVCondensedMissing is like ReadCSVCondensed, except every pair of
+// observations that is not given has a dissimilarity of missing instead of
+// causing an error. The missing value is typically math.Inf(1), to indicate
+// that the observations are unrelated.
+func ReadCSVCondensedMissing(
+	r io.Reader,
+	observations int,
+	missing float64,
+) ([]float64, error) {
+	return readCSVCondensed(r, observations, &missing)
+}
+
+// readCSVCondensed implements ReadCSVCondensed and ReadCSVCondensedMissing,
+// where a nil missing value means that every pair must be given.
+func readCSVCondensed(r io.Reader, observations int, missing *float64) ([]float64, error) {
+	if observations < 0 {
+		return nil, fmt.Errorf(
+			"expected non-negative number of observations, but got %d",
+			observations)
+	}
+	matrix := make([]float64, (observations*(observations-1))/2)
+	given := make([]bool, len(matrix))
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 3
+	cr.ReuseRecord = true
+	for first := true; ; first = false {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Parse errors from encoding/csv already include the line.
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("reading CSV: %v", err)
+		}
+		line, _ := cr.FieldPos(0)
+
+		i, errI := strconv.Atoi(strings.TrimSpace(record[0]))
+		j, errJ := strconv.Atoi(strings.TrimSpace(record[1]))
+		d, errD := strconv.ParseFloat(strings.TrimSpace(record[2]), 64)
+		if first && errI != nil && errJ != nil && errD != nil {
+			continue
+		}
+		for _, err := range []error{errI, errJ, errD} {
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+		}
+		if i < 0 || i >= observations || j < 0 || j >= observations {
+			return nil, fmt.Errorf(
+				"line %d: expected observations in range [0, %d), but got (%d, %d)",
+				line, observations, i, j)
+		}
+		if i == j {
+			return nil, fmt.Errorf(
+				"line %d: expected distinct observations, but got (%d, %d)",
+				line, i, j)
+		}
+		k := CondensedIndex(observations, i, j)
+		if given[k] {
+			return nil, fmt.Errorf(
+				"line %d: observations (%d, %d) were already given", line, i, j)
+		}
+		given[k] = true
+		matrix[k] = d
+	}
+
+	for k, ok := range given {
+		if ok {
+			continue
+		}
+		if missing == nil {
+			i, j := condensedPair(observations, k)
+			return nil, fmt.Errorf(
+				"no dissimilarity given for observations (%d, %d)", i, j)
+		}
+		matrix[k] = *missing
+	}
+	return matrix, nil
+}
@@ -0,0 +1,76 @@
+package kodama
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestReadCSVCondensed(t *testing.T) {
+	input := "i,j,distance\n" +
+		"0,1,1.5\n" +
+		" 2 , 0 , 3\n" +
+		"1,2,2.5\n"
+	got, err := ReadCSVCondensed(strings.NewReader(input), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFloatsApproxEq(t, got, []float64{1.5, 3, 2.5})
+
+	// Without a header.
+	got, err = ReadCSVCondensed(strings.NewReader("1,0,4\n"), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFloatsApproxEq(t, got, []float64{4})
+
+	got, err = ReadCSVCondensed(strings.NewReader(""), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("expected empty matrix, but got %v\n", got)
+	}
+}
+
+func TestReadCSVCondensedMissing(t *testing.T) {
+	input := "0,2,3\n"
+	if _, err := ReadCSVCondensed(strings.NewReader(input), 3); err == nil {
+		t.Fatal("expected error for missing pairs")
+	}
+	got, err := ReadCSVCondensedMissing(strings.NewReader(input), 3, math.Inf(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsInf(got[0], 1) || got[1] != 3 || !math.IsInf(got[2], 1) {
+		t.Fatalf("expected [+Inf 3 +Inf], but got %v\n", got)
+	}
+}
+
+func TestReadCSVCondensedInvalid(t *testing.T) {
+	tests := []struct {
+		input string
+		line  string
+	}{
+		{"0,1,1\n0,1\n", "line 2"},
+		{"0,1,1\n0,x,2\n", "line 2"},
+		{"0,1,1\n0,3,2\n", "line 2"},
+		{"0,1,1\n\n1,1,2\n", "line 3"},
+		{"0,1,1\n1,0,2\n", "line 2"},
+		{"0,1,abc\n", "line 1"},
+		{"0,1,\"1\n", "line 1"},
+	}
+	for _, test := range tests {
+		_, err := ReadCSVCondensedMissing(strings.NewReader(test.input), 3, 0)
+		if err == nil {
+			t.Fatalf("%q: expected error\n", test.input)
+		}
+		if !strings.Contains(err.Error(), test.line) {
+			t.Fatalf("%q: expected error mentioning %s, but got %v\n",
+				test.input, test.line, err)
+		}
+	}
+	if _, err := ReadCSVCondensed(strings.NewReader(""), -1); err == nil {
+		t.Fatal("expected error for negative observations")
+	}
+}
@@ -0,0 +1,477 @@
+package kodama
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// FlatClusters cuts this dendrogram at the given dissimilarity threshold and
+// returns a flat clustering of its observations.
+//
+// The returned slice has length Observations(), where the ith element is the
+// cluster label assigned to the ith observation. Two observations share a
+// label if and only if they are joined by merges whose dissimilarities are
+// all less than or equal to threshold. Put differently, a step is applied
+// only when its dissimilarity, and the dissimilarity of every step beneath
+// it, is at most threshold. (This only matters for methods like centroid or
+// median linkage, which may produce steps whose dissimilarity is smaller
+// than that of the steps beneath them.)
+//
+// Labels are contiguous and start at 0. They are assigned in order of each
+// cluster's smallest observation index, so observation 0 always has label
+// 0, and the next observation not in cluster 0 has label 1, and so on.
+//
+// If threshold is smaller than every merge dissimilarity, then every
+// observation is in its own cluster. If threshold is greater than or equal
+// to every merge dissimilarity, then every observation has label 0.
+func (dend *Dendrogram) FlatClusters(threshold float64) []int {
+	steps := dend.Steps()
+	heights := make([]float64, len(steps))
+	for i, s := range steps {
+		heights[i] = s.Dissimilarity
+	}
+	return cutByCriterion(dend.Observations(), steps, heights, threshold)
+}
+
+// FlatClustersByCount cuts this dendrogram such that there are exactly k
+// clusters and returns a flat clustering of its observations.
+//
+// The clustering is formed by applying only the first Observations() - k
+// steps of this dendrogram. The returned slice has length Observations(),
+// where the ith element is the cluster label assigned to the ith
+// observation. Labels are contiguous and deterministic, and are assigned in
+// the same order as FlatClusters.
+//
+// If k is less than 1 or greater than Observations(), then this method
+// panics.
+func (dend *Dendrogram) FlatClustersByCount(k int) []int {
+	obs := dend.Observations()
+	if k < 1 || k > obs {
+		panic(fmt.Errorf(
+			"expected number of clusters in range [1, %d], but got %d",
+			obs, k))
+	}
+	merges := obs - k
+	return flatLabels(obs, dend.Steps(), func(i int) bool {
+		return i < merges
+	})
+}
+
+// FlatClustersMaxSize cuts this dendrogram such that no cluster has more
+// than maxSize observations and returns a flat clustering of its
+// observations.
+//
+// Merges are applied in order, and any merge that would create a cluster
+// with more than maxSize observations is refused. Since a cluster is never
+// smaller than the clusters beneath it, this means a step is applied if and
+// only if its Size is at most maxSize, and each resulting cluster is a
+// largest subtree of this dendrogram whose size is at most maxSize. The
+// result is deterministic, and labels are assigned in the same order as
+// FlatClusters.
+//
+// If maxSize is less than 1, then this method panics.
+func (dend *Dendrogram) FlatClustersMaxSize(maxSize int) []int {
+	if maxSize < 1 {
+		panic(fmt.Errorf(
+			"expected maximum cluster size of at least 1, but got %d", maxSize))
+	}
+	steps := dend.Steps()
+	return flatLabels(dend.Observations(), steps, func(i int) bool {
+		return steps[i].Size <= maxSize
+	})
+}
+
+// FlatClustersMinSize cuts this dendrogram at the given dissimilarity
+// threshold, as in FlatClusters, and then folds every cluster with fewer
+// than minSize observations into a larger cluster.
+//
+// A cluster with at least minSize observations is large, and keeps all of
+// its members. The members of every other cluster are reassigned to the
+// large cluster it would merge with next when moving up the tree: starting
+// from the small cluster, the first ancestor in this dendrogram that
+// contains any large cluster is found, and the members are reassigned to a
+// large cluster beneath that ancestor. Large clusters are never merged with
+// each other, and every member of a small cluster is reassigned to the same
+// large cluster.
+//
+// All of the large clusters beneath that ancestor join the small cluster at
+// the same dissimilarity, so ties are common. They are broken in favor of
+// the large cluster with the smallest observation index, i.e., the one with
+// the smallest label in FlatClusters. After reassignment, labels are made
+// contiguous and are assigned in the same order as FlatClusters.
+//
+// If no cluster has at least minSize observations, then the result is the
+// same as FlatClusters(threshold). In a partial dendrogram, e.g., as returned
+// by Linkage64Until, small clusters are only reassigned to large clusters in
+// the same tree, so the small clusters of a tree without any large cluster
+// keep their members. If minSize is less than 1, then this method panics.
+func (dend *Dendrogram) FlatClustersMinSize(threshold float64, minSize int) []int {
+	if minSize < 1 {
+		panic(fmt.Errorf(
+			"expected minimum cluster size of at least 1, but got %d", minSize))
+	}
+	obs := dend.Observations()
+	steps := dend.Steps()
+	labels := dend.FlatClusters(threshold)
+	sizes := make([]int, obs)
+	for _, label := range labels {
+		sizes[label]++
+	}
+
+	// target[node] is the smallest label of any large cluster beneath node,
+	// or -1 if there is none.
+	target := make([]int, obs+len(steps))
+	for i, label := range labels {
+		target[i] = -1
+		if sizes[label] >= minSize {
+			target[i] = label
+		}
+	}
+	for i, s := range steps {
+		a, b := target[s.Cluster1], target[s.Cluster2]
+		switch {
+		case a == -1:
+			target[obs+i] = b
+		case b == -1:
+			target[obs+i] = a
+		default:
+			target[obs+i] = min(a, b)
+		}
+	}
+	anyLarge := false
+	for _, root := range dend.Roots() {
+		anyLarge = anyLarge || target[root] != -1
+	}
+	if !anyLarge {
+		return labels
+	}
+
+	// Push the target of the nearest ancestor with a large cluster down to
+	// every node without one. Parents always come after their children.
+	for i := len(steps) - 1; i >= 0; i-- {
+		for _, child := range [2]int{steps[i].Cluster1, steps[i].Cluster2} {
+			if target[child] == -1 {
+				target[child] = target[obs+i]
+			}
+		}
+	}
+	relabel := make(map[int]int)
+	for i := range labels {
+		// Observations in a tree without a large cluster have no target,
+		// so they stay in their own cluster.
+		label := target[i]
+		if label == -1 {
+			label = labels[i]
+		}
+		if _, ok := relabel[label]; !ok {
+			relabel[label] = len(relabel)
+		}
+		labels[i] = relabel[label]
+	}
+	return labels
+}
+
+// ThresholdForClusters returns the smallest dissimilarity threshold at which
+// FlatClusters produces exactly k clusters.
+//
+// Any threshold in the half-open interval from the returned value up to (but
+// not including) the dissimilarity of the next merge produces k clusters,
+// which makes the returned value suitable for annotating a cut line on a
+// dendrogram plot. When k is equal to Observations(), every observation is
+// in its own cluster for any threshold below the first merge, so negative
+// infinity is returned.
+//
+// An error is returned if k is not in the range [1, Observations()], or if
+// no threshold produces exactly k clusters. The latter can happen when
+// several merges share the same dissimilarity, since they are always
+// applied together. An error is also returned if k is less than the number
+// of trees of a partial dendrogram (see NumTrees), since no cut merges
+// separate trees.
+func (dend *Dendrogram) ThresholdForClusters(k int) (float64, error) {
+	obs := dend.Observations()
+	if k < 1 || k > obs {
+		return 0, fmt.Errorf(
+			"expected number of clusters in range [1, %d], but got %d",
+			obs, k)
+	}
+	if trees := dend.NumTrees(); k < trees {
+		return 0, fmt.Errorf(
+			"expected at least %d clusters for a partial dendrogram of %d trees, "+
+				"but got %d", trees, trees, k)
+	}
+	steps := dend.Steps()
+	heights := make([]float64, len(steps))
+	for i, s := range steps {
+		heights[i] = s.Dissimilarity
+	}
+	// A cut at threshold t applies exactly the steps whose subtree max is
+	// at most t, so k clusters requires applying the n - k smallest.
+	maxes := subtreeMaxes(obs, steps, heights)
+	sort.Float64s(maxes)
+	merges := obs - k
+	if merges == 0 {
+		return math.Inf(-1), nil
+	}
+	threshold := maxes[merges-1]
+	if merges < len(maxes) && maxes[merges] == threshold {
+		return 0, fmt.Errorf(
+			"no threshold produces exactly %d clusters, since multiple "+
+				"merges have dissimilarity %v", k, threshold)
+	}
+	return threshold, nil
+}
+
+// LargestGapThreshold returns a threshold that cuts this dendrogram across
+// the largest gap between consecutive merge dissimilarities, along with the
+// number of clusters that FlatClusters produces at that threshold. This is a
+// common heuristic for choosing a "natural" number of clusters without any
+// parameters.
+//
+// Merges are considered in the order in which a cut applies them, so for
+// dendrograms with inversions (see IsMonotonic), each step's dissimilarity
+// is taken to be the largest dissimilarity of any step beneath it, as in
+// FlatClusters. The threshold is the midpoint of the largest gap. If several
+// gaps are equally large, then the one with the smallest dissimilarities is
+// chosen, which produces the most clusters. The returned number of clusters
+// is always the number that the cut at the threshold actually produces, so
+// when the largest gap has zero width because every merge ties at the same
+// dissimilarity, all of the tied merges are applied.
+//
+// A dendrogram with fewer than two steps has no gaps. In that case, the
+// returned threshold is the dissimilarity of its only step, which produces
+// one cluster, or 0 if it has no steps, in which case the number of
+// clusters is Observations().
+func (dend *Dendrogram) LargestGapThreshold() (threshold float64, k int) {
+	obs := dend.Observations()
+	steps := dend.Steps()
+	if len(steps) == 0 {
+		return 0, obs
+	}
+	heights := make([]float64, len(steps))
+	for i, s := range steps {
+		heights[i] = s.Dissimilarity
+	}
+	maxes := subtreeMaxes(obs, steps, heights)
+	sort.Float64s(maxes)
+	if len(maxes) == 1 {
+		return maxes[0], 1
+	}
+	best := 0
+	for i := 1; i+1 < len(maxes); i++ {
+		if maxes[i+1]-maxes[i] > maxes[best+1]-maxes[best] {
+			best = i
+		}
+	}
+	// A zero-width gap puts the threshold on a tied dissimilarity, so count
+	// every merge that the cut applies rather than just those up to best.
+	threshold = (maxes[best] + maxes[best+1]) / 2
+	merges := sort.Search(len(maxes), func(i int) bool {
+		return maxes[i] > threshold
+	})
+	return threshold, obs - merges
+}
+
+// FlatClustersMulti cuts this dendrogram at each of the given dissimilarity
+// thresholds and returns a flat clustering for each one.
+//
+// The ith element of the returned slice is equivalent to
+// FlatClusters(thresholds[i]), but all of the clusterings are computed in a
+// single pass over the steps of this dendrogram. The thresholds may be
+// given in any order.
+func (dend *Dendrogram) FlatClustersMulti(thresholds []float64) [][]int {
+	obs := dend.Observations()
+	steps := dend.Steps()
+	heights := make([]float64, len(steps))
+	for i, s := range steps {
+		heights[i] = s.Dissimilarity
+	}
+	maxes := subtreeMaxes(obs, steps, heights)
+
+	// Visit steps in the order they are merged as the threshold increases.
+	// A step is never merged before the steps beneath it, since its
+	// subtree max is at least theirs and the sort is stable.
+	byHeight := make([]int, len(steps))
+	for i := range byHeight {
+		byHeight[i] = i
+	}
+	sort.SliceStable(byHeight, func(a, b int) bool {
+		return maxes[byHeight[a]] < maxes[byHeight[b]]
+	})
+	byThreshold := make([]int, len(thresholds))
+	for i := range byThreshold {
+		byThreshold[i] = i
+	}
+	sort.SliceStable(byThreshold, func(a, b int) bool {
+		return thresholds[byThreshold[a]] < thresholds[byThreshold[b]]
+	})
+
+	set := newUnionFind(obs + len(steps))
+	results := make([][]int, len(thresholds))
+	next := 0
+	for _, t := range byThreshold {
+		for ; next < len(byHeight) && maxes[byHeight[next]] <= thresholds[t]; next++ {
+			i := byHeight[next]
+			set.union(steps[i].Cluster1, obs+i)
+			set.union(steps[i].Cluster2, obs+i)
+		}
+		results[t] = set.labels(obs)
+	}
+	return results
+}
+
+// ClustersAtHeight cuts this dendrogram at the given dissimilarity threshold
+// and returns the members of each resulting cluster.
+//
+// Each cluster is a sorted slice of observation indices, and observations
+// that are not merged with anything at the given threshold are returned as
+// singleton clusters. The clusters are formed exactly as in FlatClusters,
+// and the ith cluster returned corresponds to flat cluster label i. So the
+// clusters are ordered by their smallest member.
+func (dend *Dendrogram) ClustersAtHeight(threshold float64) [][]int {
+	return groupLabels(dend.FlatClusters(threshold))
+}
+
+// ClustersWithLabels cuts this dendrogram at the given dissimilarity
+// threshold and returns the external IDs of the members of each resulting
+// cluster.
+//
+// The ith element of ids is the ID of the ith observation. The returned map
+// is keyed by flat cluster label, as returned by FlatClusters, and each
+// cluster's IDs are in order of observation index. An error is returned if
+// the length of ids is not equal to Observations().
+func (dend *Dendrogram) ClustersWithLabels(
+	threshold float64,
+	ids []string,
+) (map[int][]string, error) {
+	if err := checkLabelsLen(len(ids), dend.Observations()); err != nil {
+		return nil, err
+	}
+	clusters := make(map[int][]string)
+	for label, members := range dend.ClustersAtHeight(threshold) {
+		names := make([]string, len(members))
+		for i, o := range members {
+			names[i] = ids[o]
+		}
+		clusters[label] = names
+	}
+	return clusters, nil
+}
+
+// NumClustersAtHeight returns the number of clusters that FlatClusters
+// would produce at the given dissimilarity threshold, without computing the
+// cluster labels.
+//
+// This runs in time logarithmic in the number of steps by binary searching
+// for the first step whose dissimilarity exceeds threshold. That is only
+// correct when the steps are in non-decreasing order of dissimilarity, which
+// is true of every dendrogram produced by single, complete, average,
+// weighted and Ward linkage. Centroid and median linkage may produce
+// inversions (see IsMonotonic), in which case the result may differ from
+// FlatClusters, and callers should use len(ClustersAtHeight(threshold))
+// instead, which takes linear time.
+func (dend *Dendrogram) NumClustersAtHeight(threshold float64) int {
+	merges := sort.Search(dend.Len(), func(i int) bool {
+		return dend.step(i).Dissimilarity > threshold
+	})
+	return dend.Observations() - merges
+}
+
+// RelabelBySize returns a copy of the given flat clustering where clusters
+// are relabeled in descending order of size, so that label 0 is the largest
+// cluster, label 1 is the next largest and so on. Clusters of the same size
+// are ordered by their smallest member, so the result is deterministic.
+//
+// The given labels may be any integers, e.g., as returned by FlatClusters or
+// any other flat cut, and are not modified. Two observations have the same
+// label in the result if and only if they have the same label in the input.
+func RelabelBySize(labels []int) []int {
+	// Normalized labels are assigned in order of first appearance, which
+	// is also the order of each cluster's smallest member.
+	normalized, k := normalizeLabels(labels)
+	sizes := make([]int, k)
+	for _, label := range normalized {
+		sizes[label]++
+	}
+	order := make([]int, k)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return sizes[order[a]] > sizes[order[b]]
+	})
+	relabel := make([]int, k)
+	for newLabel, oldLabel := range order {
+		relabel[oldLabel] = newLabel
+	}
+	for i, label := range normalized {
+		normalized[i] = relabel[label]
+	}
+	return normalized
+}
+
+// groupLabels converts a flat clustering with contiguous labels starting at
+// 0 into the sorted members of each cluster, indexed by label.
+func groupLabels(labels []int) [][]int {
+	var clusters [][]int
+	for i, label := range labels {
+		for label >= len(clusters) {
+			clusters = append(clusters, nil)
+		}
+		clusters[label] = append(clusters[label], i)
+	}
+	if clusters == nil {
+		clusters = [][]int{}
+	}
+	return clusters
+}
+
+// cutByCriterion returns a flat clustering where a step is applied if and
+// only if its criterion value, and the criterion value of every step
+// beneath it, is less than or equal to threshold. criteria[i] is the
+// criterion value for the ith step.
+func cutByCriterion(
+	observations int,
+	steps []Step,
+	criteria []float64,
+	threshold float64,
+) []int {
+	maxes := subtreeMaxes(observations, steps, criteria)
+	return flatLabels(observations, steps, func(i int) bool {
+		return maxes[i] <= threshold
+	})
+}
+
+// subtreeMaxes returns, for each step, the largest criterion value of that
+// step or any step beneath it. criteria[i] is the criterion value for the ith
+// step.
+func subtreeMaxes(observations int, steps []Step, criteria []float64) []float64 {
+	maxes := make([]float64, len(steps))
+	subtreeMax := func(label int) float64 {
+		if label < observations {
+			return math.Inf(-1)
+		}
+		return maxes[label-observations]
+	}
+	for i, s := range steps {
+		maxes[i] = math.Max(criteria[i], math.Max(subtreeMax(s.Cluster1), subtreeMax(s.Cluster2)))
+	}
+	return maxes
+}
+
+// flatLabels returns a flat cluster label for each observation after
+// applying every step for which merge returns true.
+//
+// Callers must ensure that whenever a step is merged, all of the steps
+// beneath it are also merged. Labels are assigned as documented on
+// FlatClusters.
+func flatLabels(observations int, steps []Step, merge func(i int) bool) []int {
+	set := newUnionFind(observations + len(steps))
+	for i, s := range steps {
+		if merge(i) {
+			set.union(s.Cluster1, observations+i)
+			set.union(s.Cluster2, observations+i)
+		}
+	}
+	return set.labels(observations)
+}
@@ -0,0 +1,469 @@
+package kodama
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestFlatClusters(t *testing.T) {
+	dend := maDendrogram()
+	tests := []struct {
+		threshold float64
+		expected  []int
+	}{
+		// Below every merge, so every observation is its own cluster.
+		{1, []int{0, 1, 2, 3, 4, 5}},
+		// Exactly at the first merge.
+		{3.1237967760688776, []int{0, 1, 2, 3, 2, 4}},
+		{6, []int{0, 1, 2, 3, 2, 2}},
+		{10, []int{0, 1, 1, 2, 1, 1}},
+		// Above the root, so everything is in one cluster.
+		{100, []int{0, 0, 0, 0, 0, 0}},
+	}
+	for _, test := range tests {
+		got := dend.FlatClusters(test.threshold)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Fatalf("threshold %f: expected %v, but got %v\n",
+				test.threshold, test.expected, got)
+		}
+	}
+}
+
+func TestFlatClustersEmpty(t *testing.T) {
+	for _, obs := range []int{0, 1} {
+		dend := Linkage64([]float64{}, obs, MethodAverage)
+		if got := dend.FlatClusters(1); len(got) != obs {
+			t.Fatalf("expected %d labels, but got %v\n", obs, got)
+		}
+	}
+}
+
+func TestFlatClustersByCount(t *testing.T) {
+	dend := maDendrogram()
+	tests := []struct {
+		k        int
+		expected []int
+	}{
+		{6, []int{0, 1, 2, 3, 4, 5}},
+		{5, []int{0, 1, 2, 3, 2, 4}},
+		{3, []int{0, 1, 1, 2, 1, 1}},
+		{1, []int{0, 0, 0, 0, 0, 0}},
+	}
+	for _, test := range tests {
+		got := dend.FlatClustersByCount(test.k)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Fatalf("k=%d: expected %v, but got %v\n", test.k, test.expected, got)
+		}
+	}
+}
+
+func TestFlatClustersByCountOutOfRange(t *testing.T) {
+	dend := maDendrogram()
+	for _, k := range []int{0, maObservations + 1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expected panic for k=%d\n", k)
+				}
+			}()
+			dend.FlatClustersByCount(k)
+		}()
+	}
+}
+
+func TestFlatClustersMaxSize(t *testing.T) {
+	// In the tree below, observations 0 and 1 merge early, but then the
+	// pair is joined by 2, which makes a cluster of size 3.
+	//
+	// Sizes of the steps in order are 2, 2, 3 and 5.
+	dend := Linkage64([]float64{
+		1, 2, 10, 10,
+		3, 10, 10,
+		10, 10,
+		1,
+	}, 5, MethodSingle)
+	tests := []struct {
+		maxSize  int
+		expected []int
+	}{
+		{1, []int{0, 1, 2, 3, 4}},
+		{2, []int{0, 0, 1, 2, 2}},
+		{3, []int{0, 0, 0, 1, 1}},
+		{4, []int{0, 0, 0, 1, 1}},
+		{5, []int{0, 0, 0, 0, 0}},
+		{100, []int{0, 0, 0, 0, 0}},
+	}
+	for _, test := range tests {
+		got := dend.FlatClustersMaxSize(test.maxSize)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Fatalf("maxSize=%d: expected %v, but got %v\n",
+				test.maxSize, test.expected, got)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic for maxSize=0\n")
+		}
+	}()
+	dend.FlatClustersMaxSize(0)
+}
+
+func TestFlatClustersMinSize(t *testing.T) {
+	// Observations 0, 1 and 2 form a cluster, as do 3 and 4. Observation 5
+	// joins the first cluster before the two clusters are joined.
+	dend := Linkage64([]float64{
+		1, 2, 10, 10, 4,
+		3, 10, 10, 5,
+		10, 10, 5,
+		1, 10,
+		10,
+	}, 6, MethodSingle)
+	tests := []struct {
+		threshold float64
+		minSize   int
+		expected  []int
+	}{
+		{3, 1, []int{0, 0, 0, 1, 1, 2}},
+		{3, 2, []int{0, 0, 0, 1, 1, 0}},
+		// The cluster {3, 4} is now small too, and its nearest large
+		// cluster is above the root.
+		{3, 3, []int{0, 0, 0, 0, 0, 0}},
+		// Nothing is large, so nothing is reassigned.
+		{3, 4, []int{0, 0, 0, 1, 1, 2}},
+		{0.5, 1, []int{0, 1, 2, 3, 4, 5}},
+		// Observations 2 and 5 are each folded into {0, 1}.
+		{1, 2, []int{0, 0, 0, 1, 1, 0}},
+	}
+	for _, test := range tests {
+		got := dend.FlatClustersMinSize(test.threshold, test.minSize)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Fatalf("threshold=%v, minSize=%d: expected %v, but got %v\n",
+				test.threshold, test.minSize, test.expected, got)
+		}
+	}
+}
+
+func TestFlatClustersMinSizeTie(t *testing.T) {
+	// Observation 0 is an outlier that is joined to the two clusters {1, 2}
+	// and {3, 4} at the root, so it is assigned to the one with the smaller
+	// observation index.
+	dend := Linkage64([]float64{
+		20, 20, 20, 20,
+		1, 10, 10,
+		10, 10,
+		1,
+	}, 5, MethodSingle)
+	got := dend.FlatClustersMinSize(5, 2)
+	expected := []int{0, 0, 0, 1, 1}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, but got %v\n", expected, got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic for minSize=0\n")
+		}
+	}()
+	dend.FlatClustersMinSize(5, 0)
+}
+
+func TestFlatClustersMinSizeForest(t *testing.T) {
+	// Three trees: {0, 1, 2} joined by 3, {4, 5} joined by 6, and {7}. The
+	// last step is in the second tree, which has no large cluster at
+	// minSize 3, but 3 must still be folded into the first tree.
+	const n = 8
+	dis := make([]float64, (n*(n-1))/2)
+	for i := range dis {
+		dis[i] = 50
+	}
+	for _, p := range []SparsePair{
+		{0, 1, 1}, {0, 2, 1}, {1, 2, 1}, {2, 3, 5}, {4, 5, 1}, {5, 6, 6},
+	} {
+		dis[condensedIndex(n, p.I, p.J)] = p.D
+	}
+	dend, err := Linkage64Until(dis, n, MethodSingle, func(s Step) bool {
+		return s.Dissimilarity > 10
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		minSize  int
+		expected []int
+	}{
+		{1, []int{0, 0, 0, 1, 2, 2, 3, 4}},
+		{2, []int{0, 0, 0, 0, 1, 1, 1, 2}},
+		{3, []int{0, 0, 0, 0, 1, 1, 2, 3}},
+	}
+	for _, test := range tests {
+		got := dend.FlatClustersMinSize(2, test.minSize)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Fatalf("minSize=%d: expected %v, but got %v\n",
+				test.minSize, test.expected, got)
+		}
+	}
+}
+
+func TestThresholdForClusters(t *testing.T) {
+	dend := maDendrogram()
+	for k := 1; k <= maObservations; k++ {
+		threshold, err := dend.ThresholdForClusters(k)
+		if err != nil {
+			t.Fatalf("k=%d: %s\n", k, err)
+		}
+		if k == maObservations {
+			if !math.IsInf(threshold, -1) {
+				t.Fatalf("k=%d: expected -Inf, but got %v\n", k, threshold)
+			}
+			continue
+		}
+		if threshold != maSteps[maObservations-k-1].Dissimilarity {
+			t.Fatalf("k=%d: expected %v, but got %v\n",
+				k, maSteps[maObservations-k-1].Dissimilarity, threshold)
+		}
+		got := dend.FlatClusters(threshold)
+		if expected := dend.FlatClustersByCount(k); !reflect.DeepEqual(got, expected) {
+			t.Fatalf("k=%d: expected %v, but got %v\n", k, expected, got)
+		}
+	}
+	for _, k := range []int{0, maObservations + 1} {
+		if _, err := dend.ThresholdForClusters(k); err == nil {
+			t.Fatalf("expected error for k=%d\n", k)
+		}
+	}
+}
+
+func TestLargestGapThreshold(t *testing.T) {
+	dend := maDendrogram()
+	threshold, k := dend.LargestGapThreshold()
+	// The largest gap is between the last two merges.
+	expected := (maSteps[3].Dissimilarity + maSteps[4].Dissimilarity) / 2
+	assertFloatsApproxEq(t, []float64{threshold}, []float64{expected})
+	if k != 2 {
+		t.Fatalf("expected 2 clusters, but got %d\n", k)
+	}
+	if got := len(groupLabels(dend.FlatClusters(threshold))); got != k {
+		t.Fatalf("expected FlatClusters to produce %d clusters, but got %d\n", k, got)
+	}
+}
+
+func TestLargestGapThresholdTies(t *testing.T) {
+	// Every gap is 1, so the first one is chosen.
+	steps := []Step{
+		{Cluster1: 0, Cluster2: 1, Dissimilarity: 1, Size: 2},
+		{Cluster1: 2, Cluster2: 4, Dissimilarity: 2, Size: 3},
+		{Cluster1: 3, Cluster2: 5, Dissimilarity: 3, Size: 4},
+	}
+	dend, err := NewDendrogram(steps, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if threshold, k := dend.LargestGapThreshold(); threshold != 1.5 || k != 3 {
+		t.Fatalf("expected (1.5, 3), but got (%v, %d)\n", threshold, k)
+	}
+}
+
+func TestLargestGapThresholdTiedHeights(t *testing.T) {
+	tests := []struct {
+		matrix    []float64
+		obs       int
+		threshold float64
+		k         int
+	}{
+		// Three equidistant observations merge at the same dissimilarity.
+		{[]float64{1, 1, 1}, 3, 1, 1},
+		// Every merge ties, so there is no gap with a positive width.
+		{[]float64{1, 1, 1, 1, 1, 1}, 4, 1, 1},
+		// The largest gap is above two tied merges.
+		{[]float64{1, 5, 5, 5, 5, 1}, 4, 3, 2},
+	}
+	for _, test := range tests {
+		dend := Linkage64(test.matrix, test.obs, MethodSingle)
+		threshold, k := dend.LargestGapThreshold()
+		if threshold != test.threshold || k != test.k {
+			t.Fatalf("%v: expected (%v, %d), but got (%v, %d)\n",
+				test.matrix, test.threshold, test.k, threshold, k)
+		}
+		if got := len(groupLabels(dend.FlatClusters(threshold))); got != k {
+			t.Fatalf("%v: expected FlatClusters to produce %d clusters, but got %d\n",
+				test.matrix, k, got)
+		}
+	}
+}
+
+func TestLargestGapThresholdTrivial(t *testing.T) {
+	tests := []struct {
+		matrix    []float64
+		obs       int
+		threshold float64
+		k         int
+	}{
+		{[]float64{}, 0, 0, 0},
+		{[]float64{}, 1, 0, 1},
+		{[]float64{2}, 2, 2, 1},
+	}
+	for _, test := range tests {
+		dend := Linkage64(test.matrix, test.obs, MethodAverage)
+		threshold, k := dend.LargestGapThreshold()
+		if threshold != test.threshold || k != test.k {
+			t.Fatalf("%d observations: expected (%v, %d), but got (%v, %d)\n",
+				test.obs, test.threshold, test.k, threshold, k)
+		}
+	}
+}
+
+func TestThresholdForClustersTies(t *testing.T) {
+	// Both merges happen at dissimilarity 1, so there is no way to get
+	// exactly 3 clusters.
+	dend := Linkage64([]float64{1, 5, 5, 5, 5, 1}, 4, MethodSingle)
+	if _, err := dend.ThresholdForClusters(3); err == nil {
+		t.Fatalf("expected error for tied merges\n")
+	}
+	if _, err := dend.ThresholdForClusters(2); err != nil {
+		t.Fatalf("expected no error for k=2, but got %s\n", err)
+	}
+}
+
+func TestClustersAtHeight(t *testing.T) {
+	dend := maDendrogram()
+	tests := []struct {
+		threshold float64
+		expected  [][]int
+	}{
+		{1, [][]int{{0}, {1}, {2}, {3}, {4}, {5}}},
+		{6, [][]int{{0}, {1}, {2, 4, 5}, {3}}},
+		{10, [][]int{{0}, {1, 2, 4, 5}, {3}}},
+		{100, [][]int{{0, 1, 2, 3, 4, 5}}},
+	}
+	for _, test := range tests {
+		got := dend.ClustersAtHeight(test.threshold)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Fatalf("threshold %f: expected %v, but got %v\n",
+				test.threshold, test.expected, got)
+		}
+	}
+
+	empty := Linkage64([]float64{}, 0, MethodAverage)
+	if got := empty.ClustersAtHeight(1); got == nil || len(got) != 0 {
+		t.Fatalf("expected no clusters, but got %#v\n", got)
+	}
+}
+
+func TestClustersWithLabels(t *testing.T) {
+	dend := maDendrogram()
+	ids := []string{
+		"fitchburg", "framingham", "marlborough",
+		"northbridge", "southborough", "westborough",
+	}
+	got, err := dend.ClustersWithLabels(10, ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[int][]string{
+		0: {"fitchburg"},
+		1: {"framingham", "marlborough", "southborough", "westborough"},
+		2: {"northbridge"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, but got %v\n", expected, got)
+	}
+
+	if _, err := dend.ClustersWithLabels(10, ids[:2]); err == nil {
+		t.Fatalf("expected error for wrong number of ids\n")
+	}
+}
+
+func TestNumClustersAtHeight(t *testing.T) {
+	dend := maDendrogram()
+	thresholds := []float64{0, 1, 3.1237967760688776, 6, 10, 12.5, 25.589444117482433, 100}
+	for _, threshold := range thresholds {
+		expected := len(dend.ClustersAtHeight(threshold))
+		if got := dend.NumClustersAtHeight(threshold); got != expected {
+			t.Fatalf("threshold %f: expected %d clusters, but got %d\n",
+				threshold, expected, got)
+		}
+	}
+
+	for _, obs := range []int{0, 1} {
+		dend := Linkage64([]float64{}, obs, MethodAverage)
+		if got := dend.NumClustersAtHeight(1); got != obs {
+			t.Fatalf("expected %d clusters, but got %d\n", obs, got)
+		}
+	}
+}
+
+func TestFlatClustersMulti(t *testing.T) {
+	dend := maDendrogram()
+	thresholds := []float64{10, 1, 100, 6, 3.1237967760688776, 6}
+	got := dend.FlatClustersMulti(thresholds)
+	if len(got) != len(thresholds) {
+		t.Fatalf("expected %d clusterings, but got %d\n", len(thresholds), len(got))
+	}
+	for i, threshold := range thresholds {
+		expected := dend.FlatClusters(threshold)
+		if !reflect.DeepEqual(got[i], expected) {
+			t.Fatalf("threshold %f: expected %v, but got %v\n",
+				threshold, expected, got[i])
+		}
+	}
+	if got := dend.FlatClustersMulti(nil); len(got) != 0 {
+		t.Fatalf("expected no clusterings, but got %v\n", got)
+	}
+}
+
+func TestRelabelBySize(t *testing.T) {
+	labels := []int{7, 3, 3, 9, 9, 9, -1, 7}
+	expected := []int{1, 2, 2, 0, 0, 0, 3, 1}
+	if got := RelabelBySize(labels); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, but got %v\n", expected, got)
+	}
+	if labels[0] != 7 {
+		t.Fatal("expected labels to be unchanged")
+	}
+
+	// The clusters of a flat cut of the MA dendrogram have sizes 1 and 5.
+	got := RelabelBySize(maDendrogram().FlatClustersByCount(2))
+	expected = []int{1, 0, 0, 0, 0, 0}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, but got %v\n", expected, got)
+	}
+
+	if got := RelabelBySize([]int{}); len(got) != 0 {
+		t.Fatalf("expected no labels, but got %v\n", got)
+	}
+}
+
+func TestThresholdForClustersForest(t *testing.T) {
+	dend := maPartialDendrogram()
+	for k := 1; k < dend.NumTrees(); k++ {
+		if _, err := dend.ThresholdForClusters(k); err == nil {
+			t.Fatalf("k=%d: expected error for fewer clusters than trees\n", k)
+		}
+	}
+	for k := dend.NumTrees(); k <= maObservations; k++ {
+		threshold, err := dend.ThresholdForClusters(k)
+		if err != nil {
+			t.Fatalf("k=%d: %s\n", k, err)
+		}
+		if got := len(groupLabels(dend.FlatClusters(threshold))); got != k {
+			t.Fatalf("k=%d: expected %d clusters at %v, but got %d\n", k, k, threshold, got)
+		}
+	}
+
+	// A dendrogram stopped before its first step has no merges at all.
+	dis := append([]float64{}, maCondensedMatrix64...)
+	empty, err := Linkage64Until(dis, maObservations, MethodAverage, func(Step) bool {
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := empty.ThresholdForClusters(1); err == nil {
+		t.Fatalf("expected error for k=1 with no steps\n")
+	}
+	if _, err := empty.ThresholdForClusters(maObservations); err != nil {
+		t.Fatalf("expected no error for k=%d, but got %s\n", maObservations, err)
+	}
+}
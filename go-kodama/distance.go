@@ -0,0 +1,349 @@
+package kodama
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// Metric indicates how the dissimilarity between two feature vectors is
+// computed when building a condensed pairwise dissimilarity matrix.
+type Metric int
+
+// The available metrics for computing dissimilarities between vectors.
+const (
+	// MetricEuclidean is the straight-line distance between vectors.
+	MetricEuclidean Metric = iota
+	// MetricSquaredEuclidean is the square of the Euclidean distance.
+	//
+	// Note that MethodWard already squares the dissimilarities it is
+	// given, so it should normally be paired with MetricEuclidean.
+	MetricSquaredEuclidean
+	// MetricManhattan is the sum of absolute differences between vectors.
+	MetricManhattan
+	// MetricChebyshev is the largest absolute difference between vectors.
+	MetricChebyshev
+	// MetricCosine is one minus the cosine of the angle between vectors.
+	// It is undefined for vectors with a norm of zero.
+	MetricCosine
+)
+
+// EuclideanCondensed returns the condensed pairwise dissimilarity matrix of
+// Euclidean distances between n observations, suitable for passing to
+// Linkage64.
+//
+// This is equivalent to calling CondensedMatrix with MetricEuclidean.
+func EuclideanCondensed(data []float64, n, dim int) ([]float64, error) {
+	return CondensedMatrix(data, n, dim, MetricEuclidean)
+}
+
+// CondensedMatrix returns the condensed pairwise dissimilarity matrix of
+// distances between n observations using the given metric, suitable for
+// passing to Linkage64.
+//
+// The observations are given as a row-major n x dim matrix, such that the
+// ith observation is data[i*dim:(i+1)*dim]. If the length of data is not
+// n*dim, then an error is returned.
+//
+// If the metric is MetricCosine and any observation has a norm of zero,
+// then an error is returned, since its cosine distance to every other
+// observation is undefined.
+func CondensedMatrix(data []float64, n, dim int, metric Metric) ([]float64, error) {
+	if err := checkDataLen(len(data), n, dim); err != nil {
+		return nil, err
+	}
+	dist, err := metric.pairwise(data, n, dim)
+	if err != nil {
+		return nil, err
+	}
+	condensed := make([]float64, (n*(n-1))/2)
+	k := 0
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			condensed[k] = dist(i, j)
+			k++
+		}
+	}
+	return condensed, nil
+}
+
+// CondensedMatrixParallel is like CondensedMatrix, except it computes the
+// dissimilarities using the given number of goroutines. If workers is less
+// than or equal to zero, then runtime.NumCPU() goroutines are used.
+//
+// Each goroutine fills a disjoint range of the returned matrix, and every
+// dissimilarity is computed exactly as in CondensedMatrix, so the result is
+// bit-for-bit identical to the serial version.
+func CondensedMatrixParallel(
+	data []float64,
+	n, dim int,
+	metric Metric,
+	workers int,
+) ([]float64, error) {
+	if err := checkDataLen(len(data), n, dim); err != nil {
+		return nil, err
+	}
+	dist, err := metric.pairwise(data, n, dim)
+	if err != nil {
+		return nil, err
+	}
+	return fillCondensedParallel(n, workers, dist), nil
+}
+
+// CondensedFrom returns the condensed pairwise dissimilarity matrix of the
+// given items, where dist(a, b) returns the dissimilarity between two items.
+// The result is suitable for passing to Linkage64.
+//
+// The dissimilarity between items[i] and items[j], with i < j, is computed
+// as dist(items[i], items[j]), and dist is called exactly once for each such
+// pair.
+func CondensedFrom[T any](items []T, dist func(a, b T) float64) []float64 {
+	n := len(items)
+	condensed := make([]float64, (n*(n-1))/2)
+	k := 0
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			condensed[k] = dist(items[i], items[j])
+			k++
+		}
+	}
+	return condensed
+}
+
+// CondensedFromParallel is like CondensedFrom, except it calls dist from the
+// given number of goroutines. If workers is less than or equal to zero, then
+// runtime.NumCPU() goroutines are used. dist must be safe to call
+// concurrently.
+func CondensedFromParallel[T any](
+	items []T,
+	dist func(a, b T) float64,
+	workers int,
+) []float64 {
+	return fillCondensedParallel(len(items), workers, func(i, j int) float64 {
+		return dist(items[i], items[j])
+	})
+}
+
+// fillCondensedParallel returns a condensed matrix of n observations where
+// the entry for each pair (i, j) is dist(i, j), using the given number of
+// goroutines (or runtime.NumCPU() if workers is not positive).
+func fillCondensedParallel(n, workers int, dist func(i, j int) float64) []float64 {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	condensed := make([]float64, (n*(n-1))/2)
+	if workers > len(condensed) {
+		workers = len(condensed)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		// Split the matrix into equally sized ranges rather than by row,
+		// since earlier rows have more pairs than later ones.
+		start := len(condensed) * w / workers
+		end := len(condensed) * (w + 1) / workers
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			i, j := condensedPair(n, start)
+			for k := start; k < end; k++ {
+				condensed[k] = dist(i, j)
+				j++
+				if j == n {
+					i++
+					j = i + 1
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return condensed
+}
+
+// DedupCondensed removes duplicate observations from a row-major n x dim
+// feature matrix, so that only the unique observations need to be
+// clustered.
+//
+// The unique observations are returned as a row-major uniqueN x dim matrix,
+// in order of first appearance. originalToUnique has length n and maps each
+// original observation to the index of its unique observation, which can be
+// used to map a flat clustering of the unique observations back to the
+// original ones:
+//
+//	labels[i] = uniqueLabels[originalToUnique[i]]
+//
+// Two observations are duplicates if all of their features compare equal
+// with ==. In particular, observations containing NaN are never considered
+// duplicates. If the length of data is not n*dim, then this function panics.
+func DedupCondensed(data []float64, n, dim int) (uniqueData []float64, uniqueN int, originalToUnique []int) {
+	if err := checkDataLen(len(data), n, dim); err != nil {
+		panic(err)
+	}
+	seen := make(map[string]int)
+	key := make([]byte, 8*dim)
+	originalToUnique = make([]int, n)
+	uniqueData = make([]float64, 0, len(data))
+	for i := 0; i < n; i++ {
+		row := data[i*dim : (i+1)*dim]
+		hasNaN := false
+		for d, x := range row {
+			if x == 0 {
+				// Make -0 and +0 equivalent.
+				x = 0
+			}
+			hasNaN = hasNaN || math.IsNaN(x)
+			binary.LittleEndian.PutUint64(key[8*d:], math.Float64bits(x))
+		}
+		if !hasNaN {
+			if u, ok := seen[string(key)]; ok {
+				originalToUnique[i] = u
+				continue
+			}
+			seen[string(key)] = uniqueN
+		}
+		originalToUnique[i] = uniqueN
+		uniqueData = append(uniqueData, row...)
+		uniqueN++
+	}
+	return uniqueData, uniqueN, originalToUnique
+}
+
+// ClusterCentroids returns the mean feature vector of each cluster in the
+// given flat clustering.
+//
+// The observations are given as a row-major n x dim matrix, as in
+// CondensedMatrix, and labels[i] is the cluster label of the ith
+// observation, e.g., as returned by FlatClusters. Labels must be
+// non-negative. The returned slice is indexed by label and has length one
+// greater than the largest label, where each centroid has length dim. If a
+// label in that range has no members, then its centroid is nil.
+//
+// An error is returned if the length of data is not n*dim, if the length of
+// labels is not n or if any label is negative.
+func ClusterCentroids(data []float64, n, dim int, labels []int) ([][]float64, error) {
+	if err := checkDataLen(len(data), n, dim); err != nil {
+		return nil, err
+	}
+	if err := checkLabelsLen(len(labels), n); err != nil {
+		return nil, err
+	}
+	k := 0
+	for i, label := range labels {
+		if label < 0 {
+			return nil, fmt.Errorf(
+				"expected non-negative label for observation %d, but got %d",
+				i, label)
+		}
+		k = max(k, label+1)
+	}
+	centroids := make([][]float64, k)
+	counts := make([]int, k)
+	for i, label := range labels {
+		if centroids[label] == nil {
+			centroids[label] = make([]float64, dim)
+		}
+		for d, x := range data[i*dim : (i+1)*dim] {
+			centroids[label][d] += x
+		}
+		counts[label]++
+	}
+	for label, centroid := range centroids {
+		for d := range centroid {
+			centroid[d] /= float64(counts[label])
+		}
+	}
+	return centroids, nil
+}
+
+// pairwise returns a function that computes the dissimilarity between the
+// ith and jth observations in the given row-major data using this metric.
+//
+// The returned function does not allocate and is safe to call from multiple
+// goroutines.
+func (m Metric) pairwise(data []float64, n, dim int) (func(i, j int) float64, error) {
+	row := func(i int) []float64 {
+		return data[i*dim : (i+1)*dim]
+	}
+	switch m {
+	case MetricEuclidean:
+		return func(i, j int) float64 {
+			return math.Sqrt(squaredEuclidean(row(i), row(j)))
+		}, nil
+	case MetricSquaredEuclidean:
+		return func(i, j int) float64 {
+			return squaredEuclidean(row(i), row(j))
+		}, nil
+	case MetricManhattan:
+		return func(i, j int) float64 {
+			a, b := row(i), row(j)
+			sum := 0.0
+			for d := range a {
+				sum += math.Abs(a[d] - b[d])
+			}
+			return sum
+		}, nil
+	case MetricChebyshev:
+		return func(i, j int) float64 {
+			a, b := row(i), row(j)
+			largest := 0.0
+			for d := range a {
+				if diff := math.Abs(a[d] - b[d]); diff > largest {
+					largest = diff
+				}
+			}
+			return largest
+		}, nil
+	case MetricCosine:
+		norms := make([]float64, n)
+		for i := range norms {
+			a := row(i)
+			for d := range a {
+				norms[i] += a[d] * a[d]
+			}
+			norms[i] = math.Sqrt(norms[i])
+			if norms[i] == 0 {
+				return nil, fmt.Errorf(
+					"observation %d has zero norm, so its cosine distance is undefined",
+					i)
+			}
+		}
+		return func(i, j int) float64 {
+			a, b := row(i), row(j)
+			dot := 0.0
+			for d := range a {
+				dot += a[d] * b[d]
+			}
+			return 1 - dot/(norms[i]*norms[j])
+		}, nil
+	default:
+		return nil, fmt.Errorf("unrecognized metric: %d", int(m))
+	}
+}
+
+// squaredEuclidean returns the squared Euclidean distance between a and b,
+// which must have the same length.
+func squaredEuclidean(a, b []float64) float64 {
+	sum := 0.0
+	for d := range a {
+		diff := a[d] - b[d]
+		sum += diff * diff
+	}
+	return sum
+}
+
+// checkDataLen returns an error if the given length of a row-major feature
+// matrix is not consistent with n observations of dim features each.
+func checkDataLen(dataLen, n, dim int) error {
+	if n < 0 || dim < 0 {
+		return fmt.Errorf(
+			"expected non-negative dimensions, but got %d x %d", n, dim)
+	}
+	if dataLen != n*dim {
+		return fmt.Errorf(
+			"expected data of length %d (%d x %d), but got %d",
+			n*dim, n, dim, dataLen)
+	}
+	return nil
+}
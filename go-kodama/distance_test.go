@@ -0,0 +1,187 @@
+package kodama
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestEuclideanCondensed(t *testing.T) {
+	data := []float64{
+		0, 0,
+		3, 4,
+		6, 8,
+	}
+	got, err := EuclideanCondensed(data, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []float64{5, 10, 5}
+	assertFloatsApproxEq(t, got, expected)
+}
+
+func TestEuclideanCondensedBadLength(t *testing.T) {
+	if _, err := EuclideanCondensed([]float64{1, 2, 3}, 2, 2); err == nil {
+		t.Fatal("expected error for mismatched data length")
+	}
+	if _, err := EuclideanCondensed(nil, -1, 2); err == nil {
+		t.Fatal("expected error for negative observations")
+	}
+}
+
+func TestCondensedMatrix(t *testing.T) {
+	data := []float64{
+		1, 0,
+		0, 2,
+		-3, 0,
+	}
+	tests := []struct {
+		metric   Metric
+		expected []float64
+	}{
+		{MetricEuclidean, []float64{math.Sqrt(5), 4, math.Sqrt(13)}},
+		{MetricSquaredEuclidean, []float64{5, 16, 13}},
+		{MetricManhattan, []float64{3, 4, 5}},
+		{MetricChebyshev, []float64{2, 4, 3}},
+		{MetricCosine, []float64{1, 2, 1}},
+	}
+	for _, test := range tests {
+		got, err := CondensedMatrix(data, 3, 2, test.metric)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertFloatsApproxEq(t, got, test.expected)
+	}
+}
+
+func TestCondensedMatrixInvalid(t *testing.T) {
+	if _, err := CondensedMatrix([]float64{0, 0, 1, 1}, 2, 2, MetricCosine); err == nil {
+		t.Fatal("expected error for zero-norm vector with cosine metric")
+	}
+	if _, err := CondensedMatrix([]float64{0, 1}, 2, 1, Metric(100)); err == nil {
+		t.Fatal("expected error for unrecognized metric")
+	}
+}
+
+func TestCondensedMatrixParallel(t *testing.T) {
+	const n, dim = 37, 3
+	data := make([]float64, n*dim)
+	for i := range data {
+		data[i] = math.Sin(float64(i))
+	}
+	for _, metric := range []Metric{MetricEuclidean, MetricManhattan, MetricCosine} {
+		expected, err := CondensedMatrix(data, n, dim, metric)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, workers := range []int{-1, 0, 1, 3, 8, 1000} {
+			got, err := CondensedMatrixParallel(data, n, dim, metric, workers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, expected) {
+				t.Fatalf("metric %d with %d workers: results differ\n", metric, workers)
+			}
+		}
+	}
+
+	got, err := CondensedMatrixParallel(nil, 0, 2, MetricEuclidean, 4)
+	if err != nil || len(got) != 0 {
+		t.Fatalf("expected empty matrix, but got %v (%v)\n", got, err)
+	}
+	if _, err := CondensedMatrixParallel([]float64{1}, 2, 2, MetricEuclidean, 4); err == nil {
+		t.Fatal("expected error for mismatched data length")
+	}
+}
+
+func TestCondensedFrom(t *testing.T) {
+	type point struct{ x, y float64 }
+	points := []point{{0, 0}, {3, 4}, {6, 8}, {0, 1}}
+	dist := func(a, b point) float64 {
+		return math.Hypot(a.x-b.x, a.y-b.y)
+	}
+	expected := []float64{5, 10, 1, 5, math.Hypot(3, 3), math.Hypot(6, 7)}
+	assertFloatsApproxEq(t, CondensedFrom(points, dist), expected)
+	for _, workers := range []int{0, 1, 4, 100} {
+		got := CondensedFromParallel(points, dist, workers)
+		assertFloatsApproxEq(t, got, expected)
+	}
+
+	if got := CondensedFrom([]point{}, dist); len(got) != 0 {
+		t.Fatalf("expected empty matrix, but got %v\n", got)
+	}
+	if got := CondensedFromParallel([]point{{}}, dist, 4); len(got) != 0 {
+		t.Fatalf("expected empty matrix, but got %v\n", got)
+	}
+}
+
+func TestDedupCondensed(t *testing.T) {
+	data := []float64{
+		1, 2,
+		3, 4,
+		1, 2,
+		0, 5,
+		3, 4,
+		math.Copysign(0, -1), 5,
+		math.NaN(), 1,
+		math.NaN(), 1,
+	}
+	unique, uniqueN, mapping := DedupCondensed(data, 8, 2)
+	if uniqueN != 5 {
+		t.Fatalf("expected 5 unique observations, but got %d\n", uniqueN)
+	}
+	if expected := []int{0, 1, 0, 2, 1, 2, 3, 4}; !reflect.DeepEqual(mapping, expected) {
+		t.Fatalf("expected mapping %v, but got %v\n", expected, mapping)
+	}
+	assertFloatsApproxEq(t, unique[:6], []float64{1, 2, 3, 4, 0, 5})
+	if len(unique) != 10 {
+		t.Fatalf("expected 10 values, but got %d\n", len(unique))
+	}
+}
+
+func TestClusterCentroids(t *testing.T) {
+	data := []float64{
+		0, 0,
+		2, 4,
+		10, 10,
+		1, 2,
+		12, 14,
+	}
+	centroids, err := ClusterCentroids(data, 5, 2, []int{0, 0, 2, 0, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(centroids) != 3 {
+		t.Fatalf("expected 3 centroids, but got %d\n", len(centroids))
+	}
+	assertFloatsApproxEq(t, centroids[0], []float64{1, 2})
+	if centroids[1] != nil {
+		t.Fatalf("expected nil centroid for unused label, but got %v\n", centroids[1])
+	}
+	assertFloatsApproxEq(t, centroids[2], []float64{11, 12})
+}
+
+func TestClusterCentroidsInvalid(t *testing.T) {
+	data := []float64{0, 0, 1, 1}
+	if _, err := ClusterCentroids(data, 3, 2, []int{0, 0, 0}); err == nil {
+		t.Fatalf("expected error for mismatched data length\n")
+	}
+	if _, err := ClusterCentroids(data, 2, 2, []int{0}); err == nil {
+		t.Fatalf("expected error for mismatched labels length\n")
+	}
+	if _, err := ClusterCentroids(data, 2, 2, []int{0, -1}); err == nil {
+		t.Fatalf("expected error for negative label\n")
+	}
+}
+
+func assertFloatsApproxEq(t *testing.T, got, expected []float64) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d values, but got %d\n", len(expected), len(got))
+	}
+	for i := range got {
+		if math.Abs(got[i]-expected[i]) > 0.000001 {
+			t.Fatalf("index %d: expected %f, but got %f\n", i, expected[i], got[i])
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package kodama
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DOT writes this dendrogram to w as a directed graph in the Graphviz DOT
+// language.
+//
+// Every cluster is a node named "n" followed by its cluster label. Leaves are
+// drawn as boxes labeled by their name, and every merged cluster is labeled
+// by the dissimilarity of the step that created it. Each merged cluster has
+// an edge to each of its two children, so the root is the only node without
+// incoming edges. An empty dendrogram is written as an empty digraph.
+//
+// Leaf names are taken from labels, which is indexed by observation. If
+// labels is empty, then each leaf is named by its observation index. If
+// labels is not empty and its length is not equal to Observations(), then
+// an error is returned. An error is also returned if writing to w fails.
+func (dend *Dendrogram) DOT(w io.Writer, labels []string) error {
+	obs := dend.Observations()
+	if len(labels) > 0 && len(labels) != obs {
+		return fmt.Errorf(
+			"expected %d labels, but got %d", obs, len(labels))
+	}
+	steps := dend.Steps()
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("digraph dendrogram {\n")
+	for o := 0; o < obs; o++ {
+		name := strconv.Itoa(o)
+		if len(labels) > 0 {
+			name = labels[o]
+		}
+		fmt.Fprintf(bw, "\tn%d [label=%s, shape=box];\n", o, dotString(name))
+	}
+	for i, s := range steps {
+		label := strconv.FormatFloat(s.Dissimilarity, 'g', -1, 64)
+		fmt.Fprintf(bw, "\tn%d [label=%s];\n", obs+i, dotString(label))
+	}
+	for i, s := range steps {
+		fmt.Fprintf(bw, "\tn%d -> n%d;\n", obs+i, s.Cluster1)
+		fmt.Fprintf(bw, "\tn%d -> n%d;\n", obs+i, s.Cluster2)
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+// dotString quotes the given string as a DOT string literal. Backslashes are
+// escaped too, since Graphviz otherwise interprets sequences like \n and \N
+// in labels, and newlines are converted to \n.
+func dotString(s string) string {
+	var buf strings.Builder
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case '\n':
+			buf.WriteString(`\n`)
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
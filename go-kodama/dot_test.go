@@ -0,0 +1,64 @@
+package kodama
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDOT(t *testing.T) {
+	dend := Linkage64([]float64{1, 4, 2}, 3, MethodSingle)
+	var buf bytes.Buffer
+	if err := dend.DOT(&buf, []string{"a", `say "hi"`, `back\slash`}); err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Join([]string{
+		"digraph dendrogram {",
+		"\tn0 [label=\"a\", shape=box];",
+		"\tn1 [label=\"say \\\"hi\\\"\", shape=box];",
+		"\tn2 [label=\"back\\\\slash\", shape=box];",
+		"\tn3 [label=\"1\"];",
+		"\tn4 [label=\"2\"];",
+		"\tn3 -> n0;",
+		"\tn3 -> n1;",
+		"\tn4 -> n2;",
+		"\tn4 -> n3;",
+		"}",
+		"",
+	}, "\n")
+	if got := buf.String(); got != expected {
+		t.Fatalf("expected:\n%s\nbut got:\n%s\n", expected, got)
+	}
+}
+
+func TestDOTEmpty(t *testing.T) {
+	dend := Linkage64([]float64{}, 0, MethodAverage)
+	var buf bytes.Buffer
+	if err := dend.DOT(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "digraph dendrogram {\n}\n"; buf.String() != expected {
+		t.Fatalf("expected %q, but got %q\n", expected, buf.String())
+	}
+}
+
+func TestDOTDefaultLabels(t *testing.T) {
+	dend := maDendrogram()
+	var buf bytes.Buffer
+	if err := dend.DOT(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "\tn5 [label=\"5\", shape=box];\n") {
+		t.Fatalf("expected leaves named by index, but got:\n%s\n", buf.String())
+	}
+	if n := strings.Count(buf.String(), "->"); n != 2*(maObservations-1) {
+		t.Fatalf("expected %d edges, but got %d\n", 2*(maObservations-1), n)
+	}
+}
+
+func TestDOTInvalidLabels(t *testing.T) {
+	dend := maDendrogram()
+	if err := dend.DOT(&bytes.Buffer{}, []string{"a"}); err == nil {
+		t.Fatalf("expected error for wrong number of labels\n")
+	}
+}
@@ -0,0 +1,238 @@
+package kodama
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// encodedDendrogram is the serialized form of a dendrogram.
+type encodedDendrogram struct {
+	Observations int    `json:"observations"`
+	Steps        []Step `json:"steps"`
+}
+
+// MarshalJSON implements json.Marshaler. The dendrogram is encoded as an
+// object with its number of observations and its list of steps.
+func (dend *Dendrogram) MarshalJSON() ([]byte, error) {
+	return json.Marshal(encodedDendrogram{
+		Observations: dend.Observations(),
+		Steps:        dend.Steps(),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It accepts the encoding
+// produced by MarshalJSON.
+//
+// The decoded dendrogram is backed entirely by Go memory, so decoding does
+// not require calling into the C library. An error is returned if the
+// decoded steps are not valid, as documented on NewDendrogram. As with
+// NewDendrogram, Cluster1 may be greater than Cluster2. Unlike
+// NewDendrogram, fewer than Observations() - 1 steps are accepted, so that
+// partial dendrograms, e.g., as returned by Linkage64Until, can be decoded.
+func (dend *Dendrogram) UnmarshalJSON(data []byte) error {
+	var enc encodedDendrogram
+	if err := json.Unmarshal(data, &enc); err != nil {
+		return err
+	}
+	return dend.decode(enc)
+}
+
+// GobEncode implements gob.GobEncoder. The dendrogram is encoded as its
+// number of observations and its list of steps.
+func (dend *Dendrogram) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(encodedDendrogram{
+		Observations: dend.Observations(),
+		Steps:        dend.Steps(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode implements gob.GobDecoder. It accepts the encoding produced by
+// GobEncode.
+//
+// As with UnmarshalJSON, the decoded dendrogram is backed entirely by Go
+// memory and decoding does not call into the C library.
+func (dend *Dendrogram) GobDecode(data []byte) error {
+	var enc encodedDendrogram
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&enc); err != nil {
+		return err
+	}
+	return dend.decode(enc)
+}
+
+// decode replaces the contents of this dendrogram with the given decoded
+// dendrogram. An error is returned if the decoded steps are not valid
+// according to NewDendrogram, except that partial dendrograms are allowed.
+func (dend *Dendrogram) decode(enc encodedDendrogram) error {
+	if err := checkPartialSteps(enc.Steps, enc.Observations); err != nil {
+		return fmt.Errorf("invalid dendrogram: %v", err)
+	}
+	if enc.Steps == nil {
+		enc.Steps = []Step{}
+	}
+	dend.setSteps(enc.Steps, enc.Observations)
+	return nil
+}
+
+// SciPyLinkageMatrix returns this dendrogram as a linkage matrix in the
+// format used by SciPy's scipy.cluster.hierarchy module, which can be passed
+// directly to functions such as dendrogram and fcluster.
+//
+// The ith row is [Cluster1, Cluster2, Dissimilarity, Size] of the ith step.
+// SciPy numbers clusters exactly as this package does: observations are
+// numbered 0 through N - 1, and the cluster created by the ith row is
+// numbered N + i. Like SciPy, dendrograms produced by clustering always put
+// the smaller cluster number in the first column, and their rows are sorted
+// by dissimilarity for every method except centroid and median linkage. So
+// no renumbering is required, and clustering the same dissimilarities with
+// the same method produces the same matrix as SciPy, up to floating point
+// error and the order in which ties are broken.
+func (dend *Dendrogram) SciPyLinkageMatrix() [][4]float64 {
+	steps := dend.Steps()
+	matrix := make([][4]float64, len(steps))
+	for i, s := range steps {
+		matrix[i] = [4]float64{
+			float64(s.Cluster1),
+			float64(s.Cluster2),
+			s.Dissimilarity,
+			float64(s.Size),
+		}
+	}
+	return matrix
+}
+
+// npyMagic identifies the NumPy .npy format, version 1.0.
+var npyMagic = [8]byte{0x93, 'N', 'U', 'M', 'P', 'Y', 1, 0}
+
+// WriteNPY writes the linkage matrix returned by SciPyLinkageMatrix to w as
+// a NumPy .npy file (format version 1.0), so that it can be loaded in
+// Python with numpy.load and passed directly to scipy.cluster.hierarchy.
+//
+// The array has shape (Len(), 4) and holds little-endian float64 values in
+// row-major order. An error is returned only if writing to w fails.
+func (dend *Dendrogram) WriteNPY(w io.Writer) error {
+	matrix := dend.SciPyLinkageMatrix()
+	header := fmt.Sprintf(
+		"{'descr': '<f8', 'fortran_order': False, 'shape': (%d, 4), }",
+		len(matrix))
+	// The header is padded with spaces and terminated by a newline, such
+	// that the data starts at a multiple of 64 bytes. The 10 bytes are the
+	// magic bytes, version and header length.
+	pad := 63 - (10+len(header))%64
+	header += strings.Repeat(" ", pad) + "\n"
+
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(npyMagic[:]); err != nil {
+		return err
+	}
+	var buf [8]byte
+	binary.LittleEndian.PutUint16(buf[:], uint16(len(header)))
+	if _, err := bw.Write(buf[:2]); err != nil {
+		return err
+	}
+	if _, err := bw.WriteString(header); err != nil {
+		return err
+	}
+	for _, row := range matrix {
+		for _, x := range row {
+			binary.LittleEndian.PutUint64(buf[:], math.Float64bits(x))
+			if _, err := bw.Write(buf[:]); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// condensedMagic identifies the binary format written by WriteCondensed.
+var condensedMagic = [8]byte{'K', 'O', 'D', 'A', 'M', 'A', 'C', '1'}
+
+// WriteCondensed writes the given condensed dissimilarity matrix to w in a
+// compact binary format that can be read back with ReadCondensed.
+//
+// The format consists of the 8 magic bytes "KODAMAC1", followed by the
+// number of observations as a little-endian uint64, followed by each
+// dissimilarity as a little-endian IEEE 754 float64.
+//
+// An error is returned if the length of the matrix is not consistent with
+// the number of observations, or if writing to w fails.
+func WriteCondensed(w io.Writer, matrix []float64, observations int) error {
+	if err := checkMatrixLen(len(matrix), observations); err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(condensedMagic[:]); err != nil {
+		return err
+	}
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(observations))
+	if _, err := bw.Write(buf[:]); err != nil {
+		return err
+	}
+	for _, x := range matrix {
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(x))
+		if _, err := bw.Write(buf[:]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadCondensed reads a condensed dissimilarity matrix written by
+// WriteCondensed from r, along with its number of observations.
+//
+// Exactly observations-choose-2 dissimilarities are read after the header,
+// so any data following the matrix in r is left unread. An error is returned
+// if the magic bytes do not match, if the number of observations is too
+// large to represent or if r ends before the full matrix has been read.
+func ReadCondensed(r io.Reader) (matrix []float64, observations int, err error) {
+	var header [16]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, 0, fmt.Errorf("reading condensed matrix header: %v", err)
+	}
+	if !bytes.Equal(header[:8], condensedMagic[:]) {
+		return nil, 0, fmt.Errorf(
+			"expected magic bytes %q, but got %q", condensedMagic[:], header[:8])
+	}
+	obs := binary.LittleEndian.Uint64(header[8:])
+	// Ensure that observations-choose-2 float64s fit in memory addressable
+	// by an int, without overflowing when computing the length.
+	if obs > 1<<31 || obs*(obs-1)/2 > math.MaxInt/8 {
+		return nil, 0, fmt.Errorf(
+			"number of observations %d is too large", obs)
+	}
+	observations = int(obs)
+	length := (observations * (observations - 1)) / 2
+
+	// Read in bounded chunks rather than trusting the header with one
+	// large allocation up front.
+	const chunk = 1 << 13
+	var buf [8 * chunk]byte
+	matrix = make([]float64, 0, min(length, chunk))
+	for len(matrix) < length {
+		n := min(length-len(matrix), chunk)
+		if _, err := io.ReadFull(r, buf[:8*n]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, 0, fmt.Errorf(
+				"reading condensed matrix of length %d: %v", length, err)
+		}
+		for i := 0; i < n; i++ {
+			bits := binary.LittleEndian.Uint64(buf[8*i:])
+			matrix = append(matrix, math.Float64frombits(bits))
+		}
+	}
+	return matrix, observations, nil
+}
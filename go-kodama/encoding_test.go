@@ -0,0 +1,254 @@
+package kodama
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	dend := maDendrogram()
+	data, err := json.Marshal(dend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"cluster1":2,"cluster2":4`) {
+		t.Fatalf("expected lowercase step fields, but got %s\n", data)
+	}
+
+	var decoded Dendrogram
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	assertSameDendrogram(t, &decoded, dend)
+}
+
+func TestJSONRoundTripEmpty(t *testing.T) {
+	dend := Linkage64([]float64{}, 1, MethodAverage)
+	data, err := json.Marshal(dend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Dendrogram
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	assertSameDendrogram(t, &decoded, dend)
+}
+
+func TestJSONInvalid(t *testing.T) {
+	data := `{"observations":3,"steps":[{"cluster1":0,"cluster2":5,"dissimilarity":1,"size":2}]}`
+	var decoded Dendrogram
+	if err := json.Unmarshal([]byte(data), &decoded); err == nil {
+		t.Fatal("expected error for invalid steps")
+	}
+}
+
+func TestJSONUnorderedClusters(t *testing.T) {
+	// NewDendrogram accepts Cluster1 > Cluster2, so decoding must too.
+	data := `{"observations":3,"steps":[` +
+		`{"cluster1":1,"cluster2":0,"dissimilarity":1,"size":2},` +
+		`{"cluster1":3,"cluster2":2,"dissimilarity":2,"size":3}]}`
+	var decoded Dendrogram
+	if err := json.Unmarshal([]byte(data), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if s := decoded.Steps()[1]; s.Cluster1 != 3 || s.Cluster2 != 2 {
+		t.Fatalf("expected clusters (3, 2), but got (%d, %d)\n", s.Cluster1, s.Cluster2)
+	}
+}
+
+func TestGobRoundTrip(t *testing.T) {
+	for _, dend := range []*Dendrogram{
+		maDendrogram(),
+		Linkage64([]float64{}, 0, MethodAverage),
+	} {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(dend); err != nil {
+			t.Fatal(err)
+		}
+		var decoded *Dendrogram
+		if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+			t.Fatal(err)
+		}
+		assertSameDendrogram(t, decoded, dend)
+	}
+}
+
+func assertSameDendrogram(t *testing.T, got, expected *Dendrogram) {
+	t.Helper()
+	if got.Observations() != expected.Observations() {
+		t.Fatalf("expected %d observations, but got %d\n",
+			expected.Observations(), got.Observations())
+	}
+	if got.Len() != expected.Len() {
+		t.Fatalf("expected %d steps, but got %d\n", expected.Len(), got.Len())
+	}
+	if !reflect.DeepEqual(got.Steps(), expected.Steps()) {
+		t.Fatalf("expected steps %v, but got %v\n", expected.Steps(), got.Steps())
+	}
+}
+
+func TestCondensedRoundTrip(t *testing.T) {
+	for _, obs := range []int{0, 1, maObservations} {
+		matrix := maCondensedMatrix64[:(obs*(obs-1))/2]
+		var buf bytes.Buffer
+		if err := WriteCondensed(&buf, matrix, obs); err != nil {
+			t.Fatal(err)
+		}
+		if expected := 16 + 8*len(matrix); buf.Len() != expected {
+			t.Fatalf("expected %d bytes, but got %d\n", expected, buf.Len())
+		}
+		got, gotObs, err := ReadCondensed(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotObs != obs {
+			t.Fatalf("expected %d observations, but got %d\n", obs, gotObs)
+		}
+		if !reflect.DeepEqual(got, matrix) {
+			t.Fatalf("expected %v, but got %v\n", matrix, got)
+		}
+	}
+}
+
+func TestWriteCondensedInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCondensed(&buf, []float64{1, 2}, 3); err == nil {
+		t.Fatalf("expected error for mismatched matrix length\n")
+	}
+}
+
+func TestReadCondensedInvalid(t *testing.T) {
+	var valid bytes.Buffer
+	if err := WriteCondensed(&valid, maCondensedMatrix64, maObservations); err != nil {
+		t.Fatal(err)
+	}
+	data := valid.Bytes()
+
+	badMagic := append([]byte{}, data...)
+	badMagic[0] = 'X'
+	huge := append([]byte{}, data[:16]...)
+	for i := 8; i < 16; i++ {
+		huge[i] = 0xFF
+	}
+	tests := map[string][]byte{
+		"empty":     {},
+		"header":    data[:12],
+		"magic":     badMagic,
+		"truncated": data[:len(data)-1],
+		"huge":      huge,
+	}
+	for name, input := range tests {
+		if _, _, err := ReadCondensed(bytes.NewReader(input)); err == nil {
+			t.Fatalf("%s: expected error\n", name)
+		}
+	}
+}
+
+func TestSciPyLinkageMatrix(t *testing.T) {
+	// The rows are maSteps in SciPy's four column format.
+	expected := [][4]float64{
+		{2, 4, 3.1237967760688776, 2},
+		{5, 6, 5.757158112027513, 3},
+		{1, 7, 8.1392602685723, 4},
+		{3, 8, 12.483148228609206, 5},
+		{0, 9, 25.589444117482433, 6},
+	}
+	got := maDendrogram().SciPyLinkageMatrix()
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d rows, but got %d\n", len(expected), len(got))
+	}
+	for i := range got {
+		assertFloatsApproxEq(t, got[i][:], expected[i][:])
+	}
+
+	if got := Linkage64([]float64{}, 1, MethodAverage).SciPyLinkageMatrix(); len(got) != 0 {
+		t.Fatalf("expected empty matrix, but got %v\n", got)
+	}
+}
+
+func TestWriteNPY(t *testing.T) {
+	var buf bytes.Buffer
+	dend := maDendrogram()
+	if err := dend.WriteNPY(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if !bytes.Equal(data[:8], npyMagic[:]) {
+		t.Fatalf("expected magic bytes %q, but got %q\n", npyMagic[:], data[:8])
+	}
+	headerLen := int(binary.LittleEndian.Uint16(data[8:10]))
+	if (10+headerLen)%64 != 0 {
+		t.Fatalf("expected data aligned to 64 bytes, but got offset %d\n", 10+headerLen)
+	}
+	header := string(data[10 : 10+headerLen])
+	expected := "{'descr': '<f8', 'fortran_order': False, 'shape': (5, 4), }"
+	if !strings.HasPrefix(header, expected) || !strings.HasSuffix(header, "\n") {
+		t.Fatalf("expected header %q, but got %q\n", expected, header)
+	}
+
+	body := data[10+headerLen:]
+	matrix := dend.SciPyLinkageMatrix()
+	if len(body) != 8*4*len(matrix) {
+		t.Fatalf("expected %d bytes of data, but got %d\n", 8*4*len(matrix), len(body))
+	}
+	for i, row := range matrix {
+		for j, x := range row {
+			bits := binary.LittleEndian.Uint64(body[8*(4*i+j):])
+			if got := math.Float64frombits(bits); got != x {
+				t.Fatalf("(%d, %d): expected %v, but got %v\n", i, j, x, got)
+			}
+		}
+	}
+}
+
+func TestWriteNPYEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Linkage64([]float64{}, 1, MethodAverage).WriteNPY(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// There is no data, so the file ends with the header's newline.
+	if buf.Len()%64 != 0 || !strings.HasSuffix(buf.String(), "\n") {
+		t.Fatalf("expected only a padded header, but got %q\n", buf.String())
+	}
+	if !strings.Contains(buf.String(), "'shape': (0, 4)") {
+		t.Fatalf("expected empty shape, but got %q\n", buf.String())
+	}
+}
+
+func TestPartialRoundTrip(t *testing.T) {
+	dend := maPartialDendrogram()
+	data, err := json.Marshal(dend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromJSON Dendrogram
+	if err := json.Unmarshal(data, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	assertSameDendrogram(t, &fromJSON, dend)
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(dend); err != nil {
+		t.Fatal(err)
+	}
+	var fromGob *Dendrogram
+	if err := gob.NewDecoder(&buf).Decode(&fromGob); err != nil {
+		t.Fatal(err)
+	}
+	assertSameDendrogram(t, fromGob, dend)
+
+	// Too many steps are still rejected.
+	tooMany := `{"observations":2,"steps":[` +
+		`{"cluster1":0,"cluster2":1,"dissimilarity":1,"size":2},` +
+		`{"cluster1":2,"cluster2":0,"dissimilarity":2,"size":3}]}`
+	if err := json.Unmarshal([]byte(tooMany), &fromJSON); err == nil {
+		t.Fatalf("expected error for too many steps\n")
+	}
+}
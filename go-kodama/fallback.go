@@ -0,0 +1,582 @@
+package kodama
+
+import (
+	"math"
+	"sort"
+)
+
+// This file contains a port of the clustering algorithms in the Rust library
+// to pure Go. It is used in place of the C library when cgo is unavailable
+// or this package is built with the kodama_purego build tag, and is
+// otherwise only exercised by tests that check it against the C library.
+//
+// The port is deliberately faithful, down to the order in which ties are
+// broken and the order of floating point operations, so that it produces
+// the same dendrograms as the C library.
+
+// float is the set of dissimilarity types that the C library supports.
+type float interface {
+	float32 | float64
+}
+
+// goLinkage clusters the given condensed matrix in Go, using the same
+// algorithm that the C library uses for the given method: a minimum
+// spanning tree for single linkage, the nearest-neighbor chain algorithm for
+// complete, average, weighted and Ward linkage, and the generic algorithm
+// for centroid and median linkage. The matrix is used as scratch space and
+// is mutated.
+//
+// The method must be valid, and the length of the matrix must be
+// consistent with the number of observations.
+func goLinkage[T float](matrix []T, observations int, method Method) []Step {
+	return goLinkageAlgo(matrix, observations, method, method.Algorithm())
+}
+
+// goLinkageAlgo is like goLinkage, except it uses the given algorithm, which
+// must support the given method.
+func goLinkageAlgo[T float](
+	matrix []T,
+	observations int,
+	method Method,
+	algo Algorithm,
+) []Step {
+	return goLinkageParams(matrix, observations, method, algo, goParams{})
+}
+
+// goParams are the extensions of goLinkageAlgo that the C library lacks.
+type goParams struct {
+	// weights[i] is the initial size of observation i in the update
+	// formulas, as documented on LinkageWeighted64, or nil if every
+	// observation has size 1. The Size of each step is always the number
+	// of observations in the merged cluster, regardless of weights.
+	weights []float64
+	// beta is the parameter of MethodFlexible.
+	beta float64
+	// onMerge, if not nil, is called with the number of merges completed
+	// so far after every merge.
+	onMerge func(completed int)
+}
+
+// goLinkageParams is like goLinkageAlgo, except it also supports the given
+// extensions. The method may also be MethodFlexible, in which case the
+// algorithm must not be AlgorithmMST.
+func goLinkageParams[T float](
+	matrix []T,
+	observations int,
+	method Method,
+	algo Algorithm,
+	params goParams,
+) []Step {
+	steps := make([]Step, 0, max(observations-1, 0))
+	if observations == 0 {
+		return steps
+	}
+	onSquares := method == MethodWard || method == MethodCentroid || method == MethodMedian
+	if onSquares {
+		for i, x := range matrix {
+			matrix[i] = x * x
+		}
+	}
+	c := &goClustering[T]{
+		matrix:       matrix,
+		observations: observations,
+		sizes:        make([]int, observations),
+		weights:      make([]T, observations),
+		beta:         T(params.beta),
+		onMerge:      params.onMerge,
+		active:       newActiveSet(observations),
+		steps:        steps,
+	}
+	for i := range c.sizes {
+		c.sizes[i] = 1
+		c.weights[i] = 1
+		if params.weights != nil {
+			c.weights[i] = T(params.weights[i])
+		}
+	}
+	if params.weights != nil && method == MethodWard {
+		// The Ward dissimilarity between two clusters is scaled by their
+		// sizes, which is 1 for unit weights but not in general.
+		for i := 0; i < observations; i++ {
+			for j := i + 1; j < observations; j++ {
+				wi, wj := c.weights[i], c.weights[j]
+				*c.dis(i, j) *= 2 * wi * wj / (wi + wj)
+			}
+		}
+	}
+	switch algo {
+	case AlgorithmMST:
+		c.mst()
+	case AlgorithmGeneric:
+		c.generic(method)
+	default:
+		c.nnChain(method)
+	}
+
+	relabelSteps(c.steps, observations, !(method == MethodCentroid || method == MethodMedian))
+	if onSquares {
+		for i := range c.steps {
+			c.steps[i].Dissimilarity = float64(T(math.Sqrt(c.steps[i].Dissimilarity)))
+		}
+	}
+	return c.steps
+}
+
+// goClustering is the state shared by the clustering algorithms.
+type goClustering[T float] struct {
+	matrix       []T
+	observations int
+	// sizes[i] is the size of the cluster whose representative is
+	// observation i.
+	sizes []int
+	// weights[i] is the size of the same cluster as used by the update
+	// formulas, which is the sum of the weights of its observations.
+	weights []T
+	// beta is the parameter of MethodFlexible.
+	beta T
+	// onMerge is as documented on goParams.
+	onMerge func(completed int)
+	// active is the set of representatives of clusters that have not yet
+	// been merged into another cluster.
+	active *activeSet
+	// steps are the merges so far, where clusters are identified by their
+	// representatives instead of their labels. (See relabelSteps.)
+	steps []Step
+}
+
+// dis returns a pointer to the dissimilarity between the clusters
+// represented by row and column, where row must be less than column.
+func (c *goClustering[T]) dis(row, column int) *T {
+	return &c.matrix[condensedIndex(c.observations, row, column)]
+}
+
+// merge records that the cluster represented by a was merged into the
+// cluster represented by b at the given dissimilarity.
+func (c *goClustering[T]) merge(a, b int, dissimilarity T) {
+	c.sizes[b] += c.sizes[a]
+	c.weights[b] += c.weights[a]
+	c.active.remove(a)
+	c.steps = append(c.steps, Step{
+		Cluster1:      a,
+		Cluster2:      b,
+		Dissimilarity: float64(dissimilarity),
+		Size:          c.sizes[b],
+	})
+	if c.onMerge != nil {
+		c.onMerge(len(c.steps))
+	}
+}
+
+// mst computes single linkage using Prim's algorithm for minimum spanning
+// trees.
+func (c *goClustering[T]) mst() {
+	n := c.observations
+	minDists := make([]T, n)
+	for i := range minDists {
+		minDists[i] = T(math.Inf(1))
+	}
+	cluster := 0
+	c.active.remove(cluster)
+	for k := 0; k < n-1; k++ {
+		minObs := c.active.start
+		minDist := minDists[minObs]
+		visit := func(x int, d T) {
+			if d < minDists[x] {
+				minDists[x] = d
+			}
+			if minDists[x] < minDist {
+				minObs, minDist = x, minDists[x]
+			}
+		}
+		for x := c.active.from(0); x < cluster; x = c.active.next[x] {
+			visit(x, *c.dis(x, cluster))
+		}
+		for x := c.active.from(cluster); x < n; x = c.active.next[x] {
+			visit(x, *c.dis(cluster, x))
+		}
+		c.merge(minObs, cluster, minDist)
+		cluster = minObs
+	}
+}
+
+// nnChain computes complete, average, weighted or Ward linkage using the
+// nearest-neighbor chain algorithm.
+func (c *goClustering[T]) nnChain(method Method) {
+	n := c.observations
+	chain := make([]int, 0, n)
+	var a, b int
+	var min T
+	for k := 0; k < n-1; k++ {
+		if len(chain) < 4 {
+			a = c.active.start
+			chain = append(chain[:0], a)
+			b = c.active.next[a]
+			min = *c.dis(a, b)
+			for x := c.active.next[b]; x < n; x = c.active.next[x] {
+				if *c.dis(a, x) < min {
+					min, b = *c.dis(a, x), x
+				}
+			}
+		} else {
+			chain = chain[:len(chain)-2]
+			b = chain[len(chain)-1]
+			chain = chain[:len(chain)-1]
+			a = chain[len(chain)-1]
+			if a < b {
+				min = *c.dis(a, b)
+			} else {
+				min = *c.dis(b, a)
+			}
+		}
+		for {
+			chain = append(chain, b)
+			for x := c.active.from(0); x < b; x = c.active.next[x] {
+				if *c.dis(x, b) < min {
+					min, a = *c.dis(x, b), x
+				}
+			}
+			for x := c.active.next[b]; x < n; x = c.active.next[x] {
+				if *c.dis(b, x) < min {
+					min, a = *c.dis(b, x), x
+				}
+			}
+			b = a
+			a = chain[len(chain)-1]
+			if b == chain[len(chain)-2] {
+				break
+			}
+		}
+		if a > b {
+			a, b = b, a
+		}
+		update := c.updateFormula(method, a, b)
+		for x := c.active.from(0); x < a; x = c.active.next[x] {
+			*c.dis(x, b) = update(*c.dis(x, a), *c.dis(x, b), x)
+		}
+		for x := c.active.next[a]; x < b; x = c.active.next[x] {
+			*c.dis(x, b) = update(*c.dis(a, x), *c.dis(x, b), x)
+		}
+		for x := c.active.next[b]; x < n; x = c.active.next[x] {
+			*c.dis(b, x) = update(*c.dis(a, x), *c.dis(b, x), x)
+		}
+		c.merge(a, b, min)
+	}
+}
+
+// generic computes linkage with any method using the generic algorithm,
+// which keeps the nearest neighbor of each cluster in a priority queue.
+func (c *goClustering[T]) generic(method Method) {
+	n := c.observations
+	nearest := make([]int, n)
+	queue := newLinkageHeap[T](n)
+	for row := 0; row < n-1; row++ {
+		min, minDist := row+1, *c.dis(row, row+1)
+		for col := row + 1; col < n; col++ {
+			if *c.dis(row, col) < minDist {
+				min, minDist = col, *c.dis(row, col)
+			}
+		}
+		queue.priorities[row] = minDist
+		nearest[row] = min
+	}
+	queue.heapify()
+
+	for k := 0; k < n-1; k++ {
+		for {
+			// The nearest neighbor of the cluster with the smallest
+			// priority may be stale, in which case it is recomputed.
+			a := queue.heap[0]
+			if *c.dis(a, nearest[a]) == queue.priorities[a] {
+				break
+			}
+			min := maxFloat[T]()
+			for x := c.active.next[a]; x < n; x = c.active.next[x] {
+				if *c.dis(a, x) < min {
+					min, nearest[a] = *c.dis(a, x), x
+				}
+			}
+			queue.setPriority(a, min)
+		}
+
+		a := queue.pop()
+		b := nearest[a]
+		dist := *c.dis(a, b)
+		update := c.updateFormula(method, a, b)
+		for x := c.active.from(0); x < a; x = c.active.next[x] {
+			*c.dis(x, b) = update(*c.dis(x, a), *c.dis(x, b), x)
+			if *c.dis(x, b) < queue.priorities[x] {
+				queue.setPriority(x, *c.dis(x, b))
+				nearest[x] = b
+			} else if nearest[x] == a {
+				nearest[x] = b
+			}
+		}
+		for x := c.active.next[a]; x < b; x = c.active.next[x] {
+			*c.dis(x, b) = update(*c.dis(a, x), *c.dis(x, b), x)
+			if *c.dis(x, b) < queue.priorities[x] {
+				queue.setPriority(x, *c.dis(x, b))
+				nearest[x] = b
+			}
+		}
+		min := queue.priorities[b]
+		for x := c.active.next[b]; x < n; x = c.active.next[x] {
+			*c.dis(b, x) = update(*c.dis(a, x), *c.dis(b, x), x)
+			if *c.dis(b, x) < min {
+				queue.setPriority(b, *c.dis(b, x))
+				nearest[b] = x
+				min = *c.dis(b, x)
+			}
+		}
+		c.merge(a, b, dist)
+	}
+}
+
+// updateFormula returns a function that computes the dissimilarity between
+// the cluster formed by merging the clusters represented by a and b and the
+// cluster represented by x, given the dissimilarities of a and b to x.
+//
+// Products are explicitly converted to T, which prevents them from being
+// fused with additions, so that results match the C library exactly.
+func (c *goClustering[T]) updateFormula(method Method, a, b int) func(dAX, dBX T, x int) T {
+	sizeA, sizeB := c.weights[a], c.weights[b]
+	dAB := *c.dis(a, b)
+	switch method {
+	case MethodSingle:
+		return func(dAX, dBX T, _ int) T {
+			if dAX < dBX {
+				return dAX
+			}
+			return dBX
+		}
+	case MethodComplete:
+		return func(dAX, dBX T, _ int) T {
+			if dAX > dBX {
+				return dAX
+			}
+			return dBX
+		}
+	case MethodAverage:
+		return func(dAX, dBX T, _ int) T {
+			return (T(sizeA*dAX) + T(sizeB*dBX)) / (sizeA + sizeB)
+		}
+	case MethodWeighted:
+		return func(dAX, dBX T, _ int) T {
+			return 0.5 * (dAX + dBX)
+		}
+	case MethodWard:
+		return func(dAX, dBX T, x int) T {
+			sizeX := c.weights[x]
+			return (T((sizeX+sizeA)*dAX) + T((sizeX+sizeB)*dBX) - T(sizeX*dAB)) /
+				(sizeA + sizeB + sizeX)
+		}
+	case MethodCentroid:
+		sizeAB := sizeA + sizeB
+		return func(dAX, dBX T, _ int) T {
+			return (T(sizeA*dAX)+T(sizeB*dBX))/sizeAB - T(sizeA*sizeB*dAB)/T(sizeAB*sizeAB)
+		}
+	case MethodMedian:
+		return func(dAX, dBX T, _ int) T {
+			return T(0.5*(dAX+dBX)) - T(dAB*0.25)
+		}
+	case MethodFlexible:
+		alpha := (1 - c.beta) / 2
+		return func(dAX, dBX T, _ int) T {
+			return T(alpha*dAX) + T(alpha*dBX) + T(c.beta*dAB)
+		}
+	}
+	panic("kodama: unsupported method: " + method.String())
+}
+
+// maxFloat returns the largest finite value of T.
+func maxFloat[T float]() T {
+	var zero T
+	if _, ok := any(zero).(float32); ok {
+		return T(math.MaxFloat32)
+	}
+	largest := math.MaxFloat64
+	return T(largest)
+}
+
+// relabelSteps converts steps whose clusters are identified by
+// representative observations into steps labeled according to the usual
+// convention, as the C library does. If sortSteps is true, then the steps
+// are first stably sorted by dissimilarity.
+func relabelSteps(steps []Step, observations int, sortSteps bool) {
+	if sortSteps {
+		sort.SliceStable(steps, func(i, j int) bool {
+			return steps[i].Dissimilarity < steps[j].Dissimilarity
+		})
+	}
+	size := func(label int) int {
+		if label < observations {
+			return 1
+		}
+		return steps[label-observations].Size
+	}
+	set := newUnionFind(observations + len(steps))
+	for i, s := range steps {
+		c1, c2 := set.find(s.Cluster1), set.find(s.Cluster2)
+		set.union(c1, observations+i)
+		set.union(c2, observations+i)
+		steps[i] = Step{
+			Cluster1:      min(c1, c2),
+			Cluster2:      max(c1, c2),
+			Dissimilarity: s.Dissimilarity,
+			Size:          size(c1) + size(c2),
+		}
+	}
+}
+
+// activeSet is an ordered set of observations supporting removal and
+// iteration in ascending order in constant time per element.
+type activeSet struct {
+	// start is the smallest active observation, or len(next) if there are
+	// none.
+	start int
+	// next[i] is the smallest active observation greater than i, or
+	// len(next) if there is none, when i is active. It is 0 when i is not
+	// active, since 0 can never follow another observation.
+	next []int
+	// prev[i] is the largest active observation less than i, when i is
+	// active and is not start.
+	prev []int
+}
+
+// newActiveSet returns a set where every observation less than size is
+// active.
+func newActiveSet(size int) *activeSet {
+	s := &activeSet{next: make([]int, size), prev: make([]int, size)}
+	for i := range s.next {
+		s.next[i] = i + 1
+		s.prev[i] = i - 1
+	}
+	return s
+}
+
+// contains returns true if and only if i is active.
+func (s *activeSet) contains(i int) bool {
+	return s.next[i] > 0
+}
+
+// remove makes i inactive. It is a no-op if i is already inactive.
+func (s *activeSet) remove(i int) {
+	if !s.contains(i) {
+		return
+	}
+	if i == s.start {
+		s.start = s.next[i]
+	} else {
+		prev, next := s.prev[i], s.next[i]
+		s.next[prev] = next
+		if next < len(s.next) {
+			s.prev[next] = prev
+		}
+	}
+	s.next[i] = 0
+}
+
+// from returns the smallest active observation greater than or equal to
+// i, or len(next) if there is none.
+func (s *activeSet) from(i int) int {
+	if i < s.start {
+		i = s.start
+	}
+	for i < len(s.next) && !s.contains(i) {
+		i++
+	}
+	return i
+}
+
+// linkageHeap is a binary min-heap of observations keyed by priority,
+// supporting changes to the priority of any observation.
+type linkageHeap[T float] struct {
+	// heap is the observations in heap order, and positions[o] is the
+	// index of observation o in heap.
+	heap       []int
+	positions  []int
+	priorities []T
+}
+
+// newLinkageHeap returns a heap of the given number of observations, each
+// with the largest possible priority. Callers should set priorities
+// directly and then call heapify.
+func newLinkageHeap[T float](size int) *linkageHeap[T] {
+	h := &linkageHeap[T]{
+		heap:       make([]int, size),
+		positions:  make([]int, size),
+		priorities: make([]T, size),
+	}
+	for i := range h.heap {
+		h.heap[i] = i
+		h.positions[i] = i
+		h.priorities[i] = maxFloat[T]()
+	}
+	return h
+}
+
+// heapify establishes the heap invariant after priorities are set.
+func (h *linkageHeap[T]) heapify() {
+	for i := len(h.heap)/2 - 1; i >= 0; i-- {
+		h.siftDown(h.heap[i])
+	}
+}
+
+// pop removes and returns the observation with the smallest priority. The
+// heap must not be empty.
+func (h *linkageHeap[T]) pop() int {
+	if len(h.heap) >= 2 {
+		h.swap(h.heap[0], h.heap[len(h.heap)-1])
+	}
+	last := h.heap[len(h.heap)-1]
+	h.heap = h.heap[:len(h.heap)-1]
+	if len(h.heap) >= 2 {
+		h.siftDown(h.heap[0])
+	}
+	return last
+}
+
+// setPriority changes the priority of the given observation, which must
+// still be in the heap.
+func (h *linkageHeap[T]) setPriority(o int, priority T) {
+	old := h.priorities[o]
+	h.priorities[o] = priority
+	if priority < old {
+		h.siftUp(o)
+	} else if priority > old {
+		h.siftDown(o)
+	}
+}
+
+func (h *linkageHeap[T]) siftUp(o int) {
+	for h.positions[o] > 0 {
+		parent := h.heap[(h.positions[o]-1)/2]
+		if h.priorities[parent] < h.priorities[o] {
+			break
+		}
+		h.swap(o, parent)
+	}
+}
+
+func (h *linkageHeap[T]) siftDown(o int) {
+	for {
+		child := o
+		i := h.positions[o]
+		if left := 2*i + 1; left < len(h.heap) && h.priorities[h.heap[left]] < h.priorities[child] {
+			child = h.heap[left]
+		}
+		if right := 2*i + 2; right < len(h.heap) && h.priorities[h.heap[right]] < h.priorities[child] {
+			child = h.heap[right]
+		}
+		if child == o {
+			return
+		}
+		h.swap(o, child)
+	}
+}
+
+// swap exchanges the positions of two observations in the heap.
+func (h *linkageHeap[T]) swap(o1, o2 int) {
+	p1, p2 := h.positions[o1], h.positions[o2]
+	h.heap[p1], h.heap[p2] = h.heap[p2], h.heap[p1]
+	h.positions[o1], h.positions[o2] = p2, p1
+}
@@ -0,0 +1,78 @@
+//go:build cgo && !kodama_purego
+
+// These tests compare the pure Go fallback against the C library, so they
+// only run when the C library is linked. In a pure Go build, Linkage64 is
+// the fallback, and the other tests check it against fixed expected steps.
+
+package kodama
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// assertGoLinkageMatches checks that goLinkage produces exactly the same
+// steps as Linkage64 and Linkage32 for the given matrix and method.
+func assertGoLinkageMatches(t *testing.T, dis []float64, n int, method Method) {
+	t.Helper()
+	expected := Linkage64Copy(dis, n, method).Steps()
+	got := goLinkage(append([]float64{}, dis...), n, method)
+	assertStepsEqual(t, method, expected, got)
+
+	dis32 := make([]float32, len(dis))
+	for i, d := range dis {
+		dis32[i] = float32(d)
+	}
+	expected = Linkage32(append([]float32{}, dis32...), n, method).Steps()
+	got = goLinkage(dis32, n, method)
+	assertStepsEqual(t, method, expected, got)
+}
+
+func assertStepsEqual(t *testing.T, method Method, expected, got []Step) {
+	t.Helper()
+	if len(expected) != len(got) {
+		t.Fatalf("%v: expected %d steps, but got %d\n", method, len(expected), len(got))
+	}
+	for i := range expected {
+		if expected[i] != got[i] {
+			t.Fatalf("%v: step %d: expected %v, but got %v\n",
+				method, i, expected[i], got[i])
+		}
+	}
+}
+
+func TestGoLinkageMA(t *testing.T) {
+	for _, method := range allMethods {
+		assertGoLinkageMatches(t, maCondensedMatrix64, maObservations, method)
+	}
+}
+
+func TestGoLinkageRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, method := range allMethods {
+		for trial := 0; trial < 50; trial++ {
+			n := rng.Intn(30)
+			dis, err := EuclideanCondensed(randomPoints(rng, n, 3), n, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertGoLinkageMatches(t, dis, n, method)
+		}
+	}
+}
+
+func TestGoLinkageTies(t *testing.T) {
+	// Small integer dissimilarities produce many ties, which must be
+	// broken in the same way as the C library.
+	rng := rand.New(rand.NewSource(2))
+	for _, method := range allMethods {
+		for trial := 0; trial < 50; trial++ {
+			n := rng.Intn(20)
+			dis := make([]float64, (n*(n-1))/2)
+			for i := range dis {
+				dis[i] = float64(1 + rng.Intn(4))
+			}
+			assertGoLinkageMatches(t, dis, n, method)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package kodama
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// GapStatistic estimates the best number of clusters for the given
+// observations using the gap statistic of Tibshirani, Walther and Hastie in
+// "Estimating the number of clusters in a data set via the gap statistic."
+//
+// The observations are given as a row-major n x dim matrix, as in
+// CondensedMatrix. They are clustered with the given metric and method, and
+// for each k from 1 to maxK, the dendrogram is cut into k clusters with
+// FlatClustersByCount. The dispersion W(k) of each cut is the sum, over its
+// clusters, of the sum of the pairwise dissimilarities within the cluster
+// divided by the cluster's size. (With MetricSquaredEuclidean, this is the
+// within-cluster sum of squares.) The same is done for refs reference data
+// sets, each of which has n observations drawn uniformly from the bounding
+// box of the given observations. The gap for k is then the mean of
+// log(W(k)) over the reference data sets minus log(W(k)) for the given
+// observations.
+//
+// The ith element of the returned gaps is the gap for k = i + 1. The
+// recommended number of clusters is the smallest k such that
+// gap(k) >= gap(k+1) - s(k+1), where s(k) is the standard deviation of
+// log(W(k)) over the reference data sets times sqrt(1 + 1/refs), or maxK if
+// there is no such k. When a cut has a dispersion of zero, e.g., when
+// k = n, its gap is infinite or NaN, and a NaN gap(k+1) is treated as
+// satisfying the condition.
+//
+// The given seed makes the reference data sets deterministic. An error is
+// returned if the method is not valid, if the length of data is not n*dim,
+// if maxK is not in the range [1, n], if refs is less than 1 or if the
+// metric cannot be computed for the data or a reference data set (see
+// CondensedMatrix).
+func GapStatistic(
+	data []float64,
+	n, dim int,
+	metric Metric,
+	method Method,
+	maxK, refs int,
+	seed int64,
+) (gaps []float64, bestK int, err error) {
+	if err := checkMethod(method); err != nil {
+		return nil, 0, err
+	}
+	if maxK < 1 || maxK > n {
+		return nil, 0, fmt.Errorf(
+			"expected maximum number of clusters in range [1, %d], but got %d",
+			n, maxK)
+	}
+	if refs < 1 {
+		return nil, 0, fmt.Errorf(
+			"expected at least 1 reference data set, but got %d", refs)
+	}
+	logW, err := logDispersions(data, n, dim, metric, method, maxK)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	lo, hi := make([]float64, dim), make([]float64, dim)
+	for d := 0; d < dim; d++ {
+		lo[d], hi[d] = math.Inf(1), math.Inf(-1)
+		for i := 0; i < n; i++ {
+			lo[d] = math.Min(lo[d], data[i*dim+d])
+			hi[d] = math.Max(hi[d], data[i*dim+d])
+		}
+	}
+	rng := rand.New(rand.NewSource(seed))
+	ref := make([]float64, n*dim)
+	sums := make([]float64, maxK)
+	sumSquares := make([]float64, maxK)
+	for b := 0; b < refs; b++ {
+		for i := range ref {
+			d := i % dim
+			ref[i] = lo[d] + rng.Float64()*(hi[d]-lo[d])
+		}
+		refLogW, err := logDispersions(ref, n, dim, metric, method, maxK)
+		if err != nil {
+			return nil, 0, fmt.Errorf("reference data set %d: %v", b, err)
+		}
+		for k, x := range refLogW {
+			sums[k] += x
+			sumSquares[k] += x * x
+		}
+	}
+
+	gaps = make([]float64, maxK)
+	s := make([]float64, maxK)
+	for k := range gaps {
+		mean := sums[k] / float64(refs)
+		variance := math.Max(sumSquares[k]/float64(refs)-mean*mean, 0)
+		gaps[k] = mean - logW[k]
+		s[k] = math.Sqrt(variance) * math.Sqrt(1+1/float64(refs))
+	}
+	for k := 1; k < maxK; k++ {
+		next := gaps[k]
+		if math.IsNaN(next) || gaps[k-1] >= next-s[k] {
+			return gaps, k, nil
+		}
+	}
+	return gaps, maxK, nil
+}
+
+// logDispersions clusters the given observations and returns the log of
+// the dispersion of the cut into k clusters, as defined by GapStatistic,
+// for each k from 1 to maxK.
+func logDispersions(
+	data []float64,
+	n, dim int,
+	metric Metric,
+	method Method,
+	maxK int,
+) ([]float64, error) {
+	condensed, err := CondensedMatrix(data, n, dim, metric)
+	if err != nil {
+		return nil, err
+	}
+	dend := Linkage64Copy(condensed, n, method)
+	defer dend.Close()
+
+	logW := make([]float64, maxK)
+	for k := 1; k <= maxK; k++ {
+		labels := dend.FlatClustersByCount(k)
+		sums := make([]float64, k)
+		sizes := make([]int, k)
+		idx := 0
+		for i := 0; i < n; i++ {
+			sizes[labels[i]]++
+			for j := i + 1; j < n; j++ {
+				if labels[i] == labels[j] {
+					sums[labels[i]] += condensed[idx]
+				}
+				idx++
+			}
+		}
+		w := 0.0
+		for c := range sums {
+			w += sums[c] / float64(sizes[c])
+		}
+		logW[k-1] = math.Log(w)
+	}
+	return logW, nil
+}
+
+// WCSSCurve returns the within-cluster sum of squares of the given
+// observations for each number of clusters k from 1 to maxK, which can be
+// plotted to look for an "elbow" where adding more clusters stops paying
+// off.
+//
+// The observations are given as a row-major n x dim matrix, as in
+// CondensedMatrix. They are clustered once using Euclidean distances and
+// the given method, and for each k, the dendrogram is cut into k clusters
+// with FlatClustersByCount. The within-cluster sum of squares of a cut is
+// the sum of the squared Euclidean distances between each observation and
+// the centroid of its cluster. The ith element of the returned slice is the
+// within-cluster sum of squares for k = i + 1.
+//
+// An error is returned if the method is not valid, if the length of data is
+// not n*dim or if maxK is not in the range [1, n].
+func WCSSCurve(data []float64, n, dim int, method Method, maxK int) ([]float64, error) {
+	if err := checkMethod(method); err != nil {
+		return nil, err
+	}
+	if maxK < 1 || maxK > n {
+		return nil, fmt.Errorf(
+			"expected maximum number of clusters in range [1, %d], but got %d",
+			n, maxK)
+	}
+	condensed, err := EuclideanCondensed(data, n, dim)
+	if err != nil {
+		return nil, err
+	}
+	dend := Linkage64(condensed, n, method)
+	defer dend.Close()
+
+	curve := make([]float64, maxK)
+	for k := 1; k <= maxK; k++ {
+		labels := dend.FlatClustersByCount(k)
+		centroids, err := ClusterCentroids(data, n, dim, labels)
+		if err != nil {
+			return nil, err
+		}
+		for i, label := range labels {
+			curve[k-1] += squaredEuclidean(data[i*dim:(i+1)*dim], centroids[label])
+		}
+	}
+	return curve, nil
+}
@@ -0,0 +1,112 @@
+package kodama
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// blobs returns n points in dim dimensions around each of the given
+// centers, with a small amount of uniform noise.
+func blobs(rng *rand.Rand, n, dim int, centers [][]float64) []float64 {
+	var data []float64
+	for _, center := range centers {
+		for i := 0; i < n; i++ {
+			for d := 0; d < dim; d++ {
+				data = append(data, center[d]+0.1*rng.Float64())
+			}
+		}
+	}
+	return data
+}
+
+func TestGapStatistic(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	centers := [][]float64{{0, 0}, {5, 5}, {0, 10}}
+	data := blobs(rng, 10, 2, centers)
+	n := len(data) / 2
+	gaps, bestK, err := GapStatistic(
+		data, n, 2, MetricEuclidean, MethodWard, 6, 20, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gaps) != 6 {
+		t.Fatalf("expected 6 gaps, but got %d\n", len(gaps))
+	}
+	if bestK != len(centers) {
+		t.Fatalf("expected best k of %d, but got %d (gaps %v)\n",
+			len(centers), bestK, gaps)
+	}
+
+	// The same seed gives the same result.
+	again, _, err := GapStatistic(
+		data, n, 2, MetricEuclidean, MethodWard, 6, 20, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFloatsApproxEq(t, again, gaps)
+}
+
+func TestGapStatisticInvalid(t *testing.T) {
+	data := []float64{0, 0, 1, 1, 2, 2}
+	tests := []struct {
+		data       []float64
+		method     Method
+		maxK, refs int
+	}{
+		{data, MethodFlexible + 1, 2, 1},
+		{data[1:], MethodAverage, 2, 1},
+		{data, MethodAverage, 0, 1},
+		{data, MethodAverage, 4, 1},
+		{data, MethodAverage, 2, 0},
+	}
+	for i, test := range tests {
+		_, _, err := GapStatistic(
+			test.data, 3, 2, MetricEuclidean, test.method, test.maxK, test.refs, 1)
+		if err == nil {
+			t.Fatalf("test %d: expected error\n", i)
+		}
+	}
+}
+
+func TestWCSSCurve(t *testing.T) {
+	// Four points on a line, where Ward linkage merges (0, 1) and (4, 5)
+	// first.
+	data := []float64{0, 1, 4, 5}
+	got, err := WCSSCurve(data, 4, 1, MethodWard, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// k=1: centroid 2.5, so 6.25 + 2.25 + 2.25 + 6.25.
+	// k=2: centroids 0.5 and 4.5, so 4 * 0.25.
+	// k=3: one pair remains merged, so 2 * 0.25.
+	// k=4: every point is its own centroid.
+	expected := []float64{17, 1, 0.5, 0}
+	assertFloatsApproxEq(t, got, expected)
+
+	rng := rand.New(rand.NewSource(1))
+	data = blobs(rng, 10, 2, [][]float64{{0, 0}, {5, 5}, {0, 10}})
+	got, err = WCSSCurve(data, 30, 2, MethodAverage, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k := 1; k < len(got); k++ {
+		if got[k] > got[k-1] {
+			t.Fatalf("expected non-increasing curve, but got %v\n", got)
+		}
+	}
+}
+
+func TestWCSSCurveInvalid(t *testing.T) {
+	data := []float64{0, 1, 4, 5}
+	if _, err := WCSSCurve(data, 4, 1, MethodFlexible+1, 2); err == nil {
+		t.Fatal("expected error for invalid method")
+	}
+	if _, err := WCSSCurve(data[1:], 4, 1, MethodWard, 2); err == nil {
+		t.Fatal("expected error for mismatched data length")
+	}
+	for _, maxK := range []int{0, 5} {
+		if _, err := WCSSCurve(data, 4, 1, MethodWard, maxK); err == nil {
+			t.Fatalf("maxK=%d: expected error\n", maxK)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package kodama
+
+import "fmt"
+
+// LinkageWeighted64 returns a hierarchical clustering of observations given
+// their pairwise dissimilarities, where each observation stands for a group
+// of identical points.
+//
+// The ith element of weights is the multiplicity of the ith observation, and
+// must be at least 1. Average, Ward and centroid linkage depend on the sizes
+// of clusters, and for them, the size of a cluster is the sum of the weights
+// of its observations. (For Ward linkage, this also scales the initial
+// dissimilarity between two observations.) So for these methods, clustering
+// weighted observations is equivalent to clustering the same data with every
+// observation repeated according to its weight, except that the merges of
+// identical points are omitted. Single, complete, weighted and median
+// linkage do not depend on cluster sizes, so weights have no effect on them.
+//
+// The Size of each returned step is still the number of observations (not
+// the total weight) in the merged cluster, so that the returned dendrogram
+// follows the same conventions as any other.
+//
+// Since the C library does not support weights, this clustering is computed
+// by the pure Go implementation of the algorithm that Method.Algorithm
+// returns for the method. (See the "Pure Go" section of the package
+// documentation.) Unlike Linkage64, the given matrix is copied and never
+// mutated. An error is returned for all of the same reasons as Linkage64E,
+// or if the length of weights is not equal to observations or any weight is
+// less than 1.
+func LinkageWeighted64(
+	condensedDissimilarityMatrix []float64,
+	observations int,
+	weights []int,
+	method Method,
+) (*Dendrogram, error) {
+	err := checkLinkageArgs(len(condensedDissimilarityMatrix), observations, method)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFinite(condensedDissimilarityMatrix, observations); err != nil {
+		return nil, err
+	}
+	if len(weights) != observations {
+		return nil, fmt.Errorf(
+			"expected %d weights, but got %d", observations, len(weights))
+	}
+	sizes := make([]float64, observations)
+	for i, w := range weights {
+		if w < 1 {
+			return nil, fmt.Errorf(
+				"expected positive weight for observation %d, but got %d", i, w)
+		}
+		sizes[i] = float64(w)
+	}
+	matrix := make([]float64, len(condensedDissimilarityMatrix))
+	copy(matrix, condensedDissimilarityMatrix)
+	steps := goLinkageParams(
+		matrix, observations, method, method.Algorithm(), goParams{weights: sizes})
+	dend := &Dendrogram{}
+	dend.setSteps(steps, observations)
+	return dend, nil
+}
@@ -0,0 +1,128 @@
+package kodama
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+var allMethods = []Method{
+	MethodSingle, MethodComplete, MethodAverage, MethodWeighted,
+	MethodWard, MethodCentroid, MethodMedian,
+}
+
+// randomPoints returns n random observations with dim features each.
+func randomPoints(rng *rand.Rand, n, dim int) []float64 {
+	data := make([]float64, n*dim)
+	for i := range data {
+		data[i] = rng.Float64()
+	}
+	return data
+}
+
+func TestLinkageWeighted64UnitWeights(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, method := range allMethods {
+		for trial := 0; trial < 20; trial++ {
+			n := 1 + rng.Intn(15)
+			dis, err := EuclideanCondensed(randomPoints(rng, n, 3), n, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			weights := make([]int, n)
+			for i := range weights {
+				weights[i] = 1
+			}
+			got, err := LinkageWeighted64(dis, n, weights, method)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := Linkage64Copy(dis, n, method)
+			if !got.Equal(expected, 1e-9) {
+				t.Fatalf("method %d, trial %d: expected %v, but got %v\n",
+					method, trial, expected.Steps(), got.Steps())
+			}
+		}
+	}
+}
+
+func TestLinkageWeighted64Duplicates(t *testing.T) {
+	// Clustering weighted observations should produce the same merge
+	// heights as clustering the repeated observations, once the merges of
+	// identical points are removed.
+	rng := rand.New(rand.NewSource(2))
+	const n, dim = 8, 2
+	points := randomPoints(rng, n, dim)
+	weights := []int{1, 3, 1, 2, 1, 1, 4, 1}
+	var repeated []float64
+	for i, w := range weights {
+		for k := 0; k < w; k++ {
+			repeated = append(repeated, points[i*dim:(i+1)*dim]...)
+		}
+	}
+	repeatedN := len(repeated) / dim
+
+	heights := func(dend *Dendrogram) []float64 {
+		var hs []float64
+		for _, s := range dend.Steps() {
+			if s.Dissimilarity > 1e-12 {
+				hs = append(hs, s.Dissimilarity)
+			}
+		}
+		sort.Float64s(hs)
+		return hs
+	}
+	for _, method := range []Method{MethodAverage, MethodWard, MethodCentroid} {
+		dis, err := EuclideanCondensed(points, n, dim)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := LinkageWeighted64(dis, n, weights, method)
+		if err != nil {
+			t.Fatal(err)
+		}
+		repeatedDis, err := EuclideanCondensed(repeated, repeatedN, dim)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := Linkage64(repeatedDis, repeatedN, method)
+		assertFloatsApproxEq(t, heights(got), heights(expected))
+	}
+}
+
+func TestLinkageWeighted64Average(t *testing.T) {
+	// The observations 0, 1 and 10 on a line, where 1 has weight 3. After
+	// merging 0 and 1, the average dissimilarity to 10 is (10 + 3*9) / 4.
+	dis := []float64{1, 10, 9}
+	dend, err := LinkageWeighted64(dis, 3, []int{1, 3, 1}, MethodAverage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Step{{0, 1, 1, 2}, {2, 3, 9.25, 3}}
+	steps := dend.Steps()
+	for i := range steps {
+		assertStepApproxEq(t, i, steps[i], expected[i])
+	}
+	if dis[1] != 10 {
+		t.Fatalf("expected matrix to be unchanged, but got %v\n", dis)
+	}
+}
+
+func TestLinkageWeighted64Invalid(t *testing.T) {
+	dis := []float64{1, 2, 3}
+	tests := []struct {
+		dis     []float64
+		weights []int
+	}{
+		{dis[:2], []int{1, 1, 1}},
+		{[]float64{1, math.NaN(), 3}, []int{1, 1, 1}},
+		{dis, []int{1, 1}},
+		{dis, []int{1, 0, 1}},
+	}
+	for i, test := range tests {
+		if _, err := LinkageWeighted64(test.dis, 3, test.weights, MethodAverage); err == nil {
+			t.Fatalf("test %d: expected error\n", i)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+//go:build gonum
+
+package kodama
+
+import "gonum.org/v1/gonum/mat"
+
+// LinkageGonum returns a hierarchical clustering of observations given their
+// pairwise dissimilarities as a gonum symmetric matrix, such as a
+// *mat.SymDense.
+//
+// The upper triangle of the matrix (not including the diagonal) is copied
+// into a newly allocated condensed matrix, which is then clustered as if by
+// Linkage64E. The diagonal is ignored and the given matrix is never mutated.
+// An error is returned if any dissimilarity is NaN or infinite.
+//
+// This adapter is only built when the gonum build tag is set (e.g., go build
+// -tags gonum), so that gonum is not a dependency of this package unless it
+// is explicitly requested.
+func LinkageGonum(sym mat.Symmetric, method Method) (*Dendrogram, error) {
+	n := sym.SymmetricDim()
+	condensed := make([]float64, 0, (n*(n-1))/2)
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			condensed = append(condensed, sym.At(i, j))
+		}
+	}
+	return Linkage64E(condensed, n, method)
+}
@@ -0,0 +1,32 @@
+//go:build gonum
+
+package kodama
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestLinkageGonum(t *testing.T) {
+	sym := mat.NewSymDense(maObservations, nil)
+	for i := 0; i < maObservations; i++ {
+		for j := i + 1; j < maObservations; j++ {
+			sym.SetSym(i, j, maCondensedMatrix64[condensedIndex(maObservations, i, j)])
+		}
+	}
+	dend, err := LinkageGonum(sym, MethodAverage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := dend.Steps()
+	for i := range steps {
+		assertStepApproxEq(t, i, steps[i], maSteps[i])
+	}
+
+	sym.SetSym(0, 1, math.NaN())
+	if _, err := LinkageGonum(sym, MethodAverage); err == nil {
+		t.Fatalf("expected error for NaN dissimilarity\n")
+	}
+}
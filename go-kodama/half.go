@@ -0,0 +1,62 @@
+package kodama
+
+import "math"
+
+// Linkage16 returns a hierarchical clustering of observations given their
+// pairwise dissimilarities as IEEE 754 half-precision floating point numbers.
+//
+// Each element of the given condensed matrix is interpreted as the bit
+// pattern of a half-precision float (binary16). Since the C library does not
+// support half-precision input, the matrix is widened into a newly allocated
+// single-precision matrix before calling Linkage32. This means the given
+// matrix is never mutated, but clustering temporarily needs three times the
+// memory of the half-precision matrix.
+//
+// Widening is exact, so the clustering is identical to what Linkage32
+// produces on the same values. However, half-precision floats only have
+// about three decimal digits of precision and a maximum finite value of
+// 65504, so dissimilarities that are distinct in the original data may be
+// equal after being rounded to half-precision. This can change which merges
+// are considered ties, and thus the shape of the dendrogram.
+//
+// Like Linkage32, this function panics if the method is not valid or the
+// length of the given matrix is not consistent with the number of
+// observations.
+func Linkage16(
+	condensedDissimilarityMatrix []uint16,
+	observations int,
+	method Method,
+) *Dendrogram {
+	err := checkLinkageArgs(len(condensedDissimilarityMatrix), observations, method)
+	if err != nil {
+		panic(err)
+	}
+	matrix := make([]float32, len(condensedDissimilarityMatrix))
+	for i, h := range condensedDissimilarityMatrix {
+		matrix[i] = halfToFloat32(h)
+	}
+	return linkage32(matrix, observations, method)
+}
+
+// halfToFloat32 converts the bit pattern of an IEEE 754 half-precision float
+// to a single-precision float. The conversion is exact.
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1F
+	frac := uint32(h) & 0x3FF
+	switch {
+	case exp == 0x1F:
+		// Infinity or NaN. Preserve the NaN payload.
+		return math.Float32frombits(sign | 0x7F800000 | frac<<13)
+	case exp != 0:
+		// Normal. Rebias the exponent from 15 to 127.
+		return math.Float32frombits(sign | (exp+127-15)<<23 | frac<<13)
+	case frac == 0:
+		// Signed zero.
+		return math.Float32frombits(sign)
+	default:
+		// Subnormal, which is frac * 2^-24.
+		f := float32(frac) * (1.0 / (1 << 24))
+		return math.Float32frombits(sign | math.Float32bits(f))
+	}
+}
@@ -0,0 +1,54 @@
+package kodama
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHalfToFloat32(t *testing.T) {
+	tests := []struct {
+		half     uint16
+		expected float32
+	}{
+		{0x0000, 0},
+		{0x3C00, 1},
+		{0xC000, -2},
+		{0x3555, 0.333251953125},
+		{0x7BFF, 65504},
+		{0x0400, 1.0 / (1 << 14)},
+		{0x0001, 1.0 / (1 << 24)},
+		{0x03FF, 1023.0 / (1 << 24)},
+		{0x7C00, float32(math.Inf(1))},
+		{0xFC00, float32(math.Inf(-1))},
+	}
+	for _, test := range tests {
+		if got := halfToFloat32(test.half); got != test.expected {
+			t.Fatalf("%#04x: expected %v, but got %v\n", test.half, test.expected, got)
+		}
+	}
+	if got := halfToFloat32(0x8000); got != 0 || !math.Signbit(float64(got)) {
+		t.Fatalf("expected -0, but got %v\n", got)
+	}
+	if got := halfToFloat32(0x7E00); !math.IsNaN(float64(got)) {
+		t.Fatalf("expected NaN, but got %v\n", got)
+	}
+}
+
+func TestLinkage16(t *testing.T) {
+	// Small integers are exactly representable in half-precision, so these
+	// dissimilarities are 2, 6, 10, 4, 8 and 3.
+	dis := []uint16{0x4000, 0x4600, 0x4900, 0x4400, 0x4800, 0x4200}
+	dend := Linkage16(dis, 4, MethodSingle)
+	expected := []Step{
+		{0, 1, 2, 2},
+		{2, 3, 3, 2},
+		{4, 5, 4, 4},
+	}
+	steps := dend.Steps()
+	if len(steps) != len(expected) {
+		t.Fatalf("expected %d steps, but got %d\n", len(expected), len(steps))
+	}
+	for i := range steps {
+		assertStepApproxEq(t, i, steps[i], expected[i])
+	}
+}
@@ -0,0 +1,140 @@
+package kodama
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// HeatmapOptions controls how a clustered heatmap is rendered by
+// RenderHeatmap.
+type HeatmapOptions struct {
+	// CellSize is the width and height of each cell of the heatmap in
+	// pixels. When zero, it defaults to 10.
+	CellSize int
+	// DendrogramSize is the space in pixels given to each of the two
+	// dendrograms drawn alongside the heatmap, measured from the leaves to
+	// the root. When zero, it defaults to 100.
+	DendrogramSize int
+	// Labels names each observation, and is indexed by observation. When
+	// empty, observations are named by their index.
+	Labels []string
+}
+
+// RenderHeatmap writes an SVG image of a clustered heatmap to w.
+//
+// The heatmap is a grid of cells where the cell in row i and column j is
+// colored by the dissimilarity between the ith and jth observations in
+// LeafOrder, so that clusters appear as blocks along the diagonal. Cells
+// range from dark blue for a dissimilarity of 0 to white for the largest
+// finite dissimilarity in the matrix. Dissimilarities outside of that range
+// are clamped to it, and NaN dissimilarities are drawn in gray. The
+// dendrogram is drawn above the columns and to the left of the rows, with
+// its leaves aligned to the grid, and each observation is labeled to the
+// right of its row and below its column.
+//
+// The condensed matrix is typically the one that dend was computed from,
+// and it is never mutated. An error is returned if the length of the matrix
+// is not consistent with dend.Observations(), if opts.Labels is not empty
+// and its length is not equal to dend.Observations(), or if writing to w
+// fails.
+func RenderHeatmap(
+	w io.Writer,
+	dend *Dendrogram,
+	condensed []float64,
+	opts HeatmapOptions,
+) error {
+	obs := dend.Observations()
+	if err := checkMatrixLen(len(condensed), obs); err != nil {
+		return err
+	}
+	if len(opts.Labels) > 0 && len(opts.Labels) != obs {
+		return fmt.Errorf(
+			"expected %d labels, but got %d", obs, len(opts.Labels))
+	}
+	if opts.CellSize == 0 {
+		opts.CellSize = 10
+	}
+	if opts.DendrogramSize == 0 {
+		opts.DendrogramSize = 100
+	}
+	cell, dendSize := float64(opts.CellSize), float64(opts.DendrogramSize)
+	// The top left corner of the grid.
+	origin := svgMargin + dendSize
+	size := int(math.Ceil(origin + float64(obs)*cell + svgLabelSpace + svgMargin))
+
+	maxDis := 0.0
+	for _, d := range condensed {
+		if !math.IsInf(d, 0) && d > maxDis {
+			maxDis = d
+		}
+	}
+
+	layout := dend.layout()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		size, size, size, size)
+	for row, i := range layout.order {
+		for col, j := range layout.order {
+			d := 0.0
+			if i != j {
+				d = condensed[CondensedIndex(obs, i, j)]
+			}
+			fmt.Fprintf(bw,
+				`<rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`+"\n",
+				svgNum(origin+float64(col)*cell), svgNum(origin+float64(row)*cell),
+				svgNum(cell), svgNum(cell), heatmapColor(d, maxDis))
+		}
+	}
+
+	// Both dendrograms have their leaves against the grid, where position p
+	// along the leaf axis is p cells from the grid's edge.
+	layout.writeSVGLinks(bw, OrientationTopDown, func(p, d float64) (x, y float64) {
+		return origin + p*cell, origin - d/layout.maxHeight*dendSize
+	}, nil)
+	layout.writeSVGLinks(bw, OrientationLeftRight, func(p, d float64) (x, y float64) {
+		return origin - d/layout.maxHeight*dendSize, origin + p*cell
+	}, nil)
+
+	edge := origin + float64(obs)*cell
+	for _, o := range layout.order {
+		label := strconv.Itoa(o)
+		if len(opts.Labels) > 0 {
+			label = opts.Labels[o]
+		}
+		p := origin + layout.pos[o]*cell
+		fmt.Fprintf(bw,
+			`<text x="%s" y="%s" font-size="10" dominant-baseline="middle">`,
+			svgNum(edge+4), svgNum(p))
+		xml.EscapeText(bw, []byte(label))
+		bw.WriteString("</text>\n")
+		fmt.Fprintf(bw,
+			`<text x="%s" y="%s" font-size="10" text-anchor="end" transform="rotate(-90 %s %s)" dominant-baseline="middle">`,
+			svgNum(p), svgNum(edge+4), svgNum(p), svgNum(edge+4))
+		xml.EscapeText(bw, []byte(label))
+		bw.WriteString("</text>\n")
+	}
+	bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
+
+// heatmapColor returns the color of a heatmap cell with the given
+// dissimilarity, where maxDis is the largest finite dissimilarity.
+func heatmapColor(d, maxDis float64) string {
+	if math.IsNaN(d) {
+		return "#cccccc"
+	}
+	t := 0.0
+	if maxDis > 0 {
+		t = math.Max(0, math.Min(1, d/maxDis))
+	}
+	// Interpolate from dark blue to white.
+	lerp := func(from, to float64) int {
+		return int(math.Round(from + t*(to-from)))
+	}
+	return fmt.Sprintf("#%02x%02x%02x", lerp(0x08, 0xff), lerp(0x30, 0xff), lerp(0x6b, 0xff))
+}
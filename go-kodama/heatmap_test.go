@@ -0,0 +1,82 @@
+package kodama
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestRenderHeatmap(t *testing.T) {
+	dend := maDendrogram()
+	labels := []string{
+		"fitchburg", "framingham", "marlborough",
+		"northbridge", "southborough", "westborough & co",
+	}
+	var buf bytes.Buffer
+	err := RenderHeatmap(&buf, dend, maCondensedMatrix64, HeatmapOptions{Labels: labels})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertWellFormedXML(t, buf.Bytes())
+	out := buf.String()
+	if got := strings.Count(out, "<rect"); got != maObservations*maObservations {
+		t.Fatalf("expected %d cells, but got %d\n", maObservations*maObservations, got)
+	}
+	// One dendrogram along each axis.
+	if got := strings.Count(out, "<path"); got != 2*(maObservations-1) {
+		t.Fatalf("expected %d links, but got %d\n", 2*(maObservations-1), got)
+	}
+	if got := strings.Count(out, "westborough &amp; co"); got != 2 {
+		t.Fatalf("expected escaped label on both axes, but got %d\n", got)
+	}
+	// The diagonal is the darkest color, and the largest dissimilarity is
+	// white (once on each side of the diagonal).
+	if got := strings.Count(out, `fill="#08306b"`); got != maObservations {
+		t.Fatalf("expected %d cells on the diagonal, but got %d\n", maObservations, got)
+	}
+	if got := strings.Count(out, `fill="#ffffff"`); got != 2 {
+		t.Fatalf("expected 2 cells with the largest dissimilarity, but got %d\n", got)
+	}
+}
+
+func TestRenderHeatmapErrors(t *testing.T) {
+	var buf bytes.Buffer
+	dend := maDendrogram()
+	if err := RenderHeatmap(&buf, dend, maCondensedMatrix64[1:], HeatmapOptions{}); err == nil {
+		t.Fatal("expected error for mismatched matrix length")
+	}
+	opts := HeatmapOptions{Labels: []string{"a"}}
+	if err := RenderHeatmap(&buf, dend, maCondensedMatrix64, opts); err == nil {
+		t.Fatal("expected error for mismatched number of labels")
+	}
+	for _, obs := range []int{0, 1} {
+		buf.Reset()
+		dend := Linkage64([]float64{}, obs, MethodAverage)
+		if err := RenderHeatmap(&buf, dend, []float64{}, HeatmapOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		assertWellFormedXML(t, buf.Bytes())
+	}
+}
+
+func TestHeatmapColor(t *testing.T) {
+	tests := []struct {
+		d, maxDis float64
+		expected  string
+	}{
+		{0, 10, "#08306b"},
+		{10, 10, "#ffffff"},
+		{20, 10, "#ffffff"},
+		{-1, 10, "#08306b"},
+		{math.Inf(1), 10, "#ffffff"},
+		{math.NaN(), 10, "#cccccc"},
+		{0, 0, "#08306b"},
+	}
+	for _, test := range tests {
+		if got := heatmapColor(test.d, test.maxDis); got != test.expected {
+			t.Fatalf("d=%v, max=%v: expected %s, but got %s\n",
+				test.d, test.maxDis, test.expected, got)
+		}
+	}
+}
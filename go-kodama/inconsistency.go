@@ -0,0 +1,89 @@
+package kodama
+
+import (
+	"fmt"
+	"math"
+)
+
+// Inconsistency returns the inconsistency statistics for each step of this
+// dendrogram, computed over the steps at most depth levels beneath it.
+//
+// For the ith step, the statistics are computed over the ith step itself
+// along with every step beneath it whose distance from it in the tree is
+// less than depth. (So a depth of 1 includes only the step itself, and a
+// depth of 2 includes the step and the steps that created its children.)
+// The ith element of the returned slice contains, in order:
+//
+//  1. the mean dissimilarity of the included steps,
+//  2. the sample standard deviation of those dissimilarities,
+//  3. the number of included steps, and
+//  4. the inconsistency coefficient, which is the ith step's dissimilarity
+//     minus the mean, divided by the standard deviation. If the standard
+//     deviation is zero, then the coefficient is zero.
+//
+// This is equivalent to SciPy's inconsistent function. If depth is less
+// than 1, then this method panics.
+func (dend *Dendrogram) Inconsistency(depth int) [][4]float64 {
+	if depth < 1 {
+		panic(fmt.Errorf("expected depth of at least 1, but got %d", depth))
+	}
+	obs := dend.Observations()
+	steps := dend.Steps()
+	stats := make([][4]float64, len(steps))
+
+	type entry struct{ step, level int }
+	var stack []entry
+	for i := range steps {
+		var sum, sumSq float64
+		count := 0
+		stack = append(stack[:0], entry{i, 0})
+		for len(stack) > 0 {
+			e := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			s := steps[e.step]
+			sum += s.Dissimilarity
+			sumSq += s.Dissimilarity * s.Dissimilarity
+			count++
+			if e.level+1 >= depth {
+				continue
+			}
+			for _, c := range [2]int{s.Cluster1, s.Cluster2} {
+				if c >= obs {
+					stack = append(stack, entry{c - obs, e.level + 1})
+				}
+			}
+		}
+
+		mean := sum / float64(count)
+		std := 0.0
+		if count > 1 {
+			variance := (sumSq - sum*sum/float64(count)) / float64(count-1)
+			if variance > 0 {
+				std = math.Sqrt(variance)
+			}
+		}
+		coefficient := 0.0
+		if std > 0 {
+			coefficient = (steps[i].Dissimilarity - mean) / std
+		}
+		stats[i] = [4]float64{mean, std, float64(count), coefficient}
+	}
+	return stats
+}
+
+// FlatClustersInconsistent returns a flat clustering of this dendrogram's
+// observations, where observations are in the same cluster if and only if
+// they are joined by steps whose inconsistency coefficients, computed by
+// Inconsistency(depth), are all less than or equal to threshold.
+//
+// This is equivalent to SciPy's fcluster with the inconsistent criterion.
+// The returned labels are assigned in the same way as FlatClusters. If depth
+// is less than 1, then this method panics.
+func (dend *Dendrogram) FlatClustersInconsistent(threshold float64, depth int) []int {
+	stats := dend.Inconsistency(depth)
+	coefficients := make([]float64, len(stats))
+	for i := range stats {
+		coefficients[i] = stats[i][3]
+	}
+	return cutByCriterion(dend.Observations(), dend.Steps(), coefficients, threshold)
+}
@@ -0,0 +1,51 @@
+package kodama
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestInconsistency(t *testing.T) {
+	dend := maDendrogram()
+	// Computed independently from the dissimilarities in maSteps.
+	expected := [][4]float64{
+		{3.1237967760688776, 0, 1, 0},
+		{4.440477444048195, 1.8620676579708173, 2, 0.7071067811865477},
+		{6.948209190299907, 1.684400588371918, 2, 0.7071067811865476},
+		{10.311204248590753, 3.0715926332566945, 2, 0.7071067811865476},
+		{19.03629617304582, 9.267550699259628, 2, 0.7071067811865476},
+	}
+	got := dend.Inconsistency(2)
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d rows, but got %d\n", len(expected), len(got))
+	}
+	for i := range got {
+		assertFloatsApproxEq(t, got[i][:], expected[i][:])
+	}
+
+	// With a depth of 3, the third step includes both steps beneath it.
+	row := dend.Inconsistency(3)[2]
+	assertFloatsApproxEq(t, row[:], []float64{
+		5.673405052222897, 2.5087804691580002, 3, 0.9828899924340517,
+	})
+
+	// A depth of 1 only ever includes the step itself.
+	for _, row := range dend.Inconsistency(1) {
+		if row[1] != 0 || row[2] != 1 || row[3] != 0 {
+			t.Fatalf("unexpected row for depth 1: %v\n", row)
+		}
+	}
+}
+
+func TestFlatClustersInconsistent(t *testing.T) {
+	dend := maDendrogram()
+	got := dend.FlatClustersInconsistent(0.5, 2)
+	if expected := []int{0, 1, 2, 3, 2, 4}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, but got %v\n", expected, got)
+	}
+	got = dend.FlatClustersInconsistent(1/math.Sqrt2+0.001, 2)
+	if expected := []int{0, 0, 0, 0, 0, 0}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, but got %v\n", expected, got)
+	}
+}
@@ -13,17 +13,39 @@
 // For more detailed information, see the documentation for the Rust library
 // at https://docs.rs/kodama. Most or all of the things should translate
 // straight-forwardly to these Go bindings.
+//
+// # Determinism
+//
+// Clustering is a deterministic function of its input. The underlying
+// algorithms use no randomness or concurrency, and steps with equal
+// dissimilarities are ordered by a stable sort, so clustering identical
+// input with the same method always produces identical steps. When several
+// pairs of clusters are tied for the smallest dissimilarity, the tie is
+// broken by the order in which the observations are given. Consequently,
+// permuting the observations of input with ties may produce a different
+// (but equally valid) dendrogram. Callers that need labels to be stable
+// across such permutations should put their observations in a canonical
+// order before clustering.
+//
+// # Pure Go
+//
+// By default, clustering is done by the C library, which must be linked
+// with cgo. When cgo is not available (e.g., with CGO_ENABLED=0 or when
+// targeting WebAssembly with GOOS=js GOARCH=wasm), or when this package is
+// built with the kodama_purego build tag, clustering is instead done by a
+// port of the same algorithms to Go, and the C library is not needed. The
+// API is the same in either case. The port produces identical dendrograms,
+// including the order in which ties are broken, but is slower. In this
+// build, every dendrogram is backed entirely by Go memory, so Close and
+// LinkageNoFinalizer64 free nothing.
 package kodama
 
-// #cgo LDFLAGS: -lkodama
-// #include "kodama.h"
-import "C"
-
 import (
 	"fmt"
+	"iter"
 	"math"
-	"reflect"
 	"runtime"
+	"strings"
 	"unsafe"
 )
 
@@ -34,6 +56,17 @@ import (
 // cluster is formed. In particular, when clusters a and b are merged into a
 // new cluster ab, then the pairwise dissimilarity between ab and every other
 // cluster is computed using one of the variants of this type.
+//
+// Ward, centroid and median linkage are only meaningful for Euclidean
+// distances. These methods square the given dissimilarities internally and
+// take the square root of each merge dissimilarity afterwards, so they
+// should be given raw (unsquared) Euclidean distances. Given such
+// distances, MethodWard produces the same dendrogram as SciPy's "ward"
+// method and R's "ward.D2" method. Passing squared distances (e.g., from
+// SquareMatrix or MetricSquaredEuclidean) squares them twice, which is
+// almost never what is wanted. R's "ward.D" method, when given squared
+// Euclidean distances, produces the squares of the merge dissimilarities
+// produced by MethodWard on the unsquared distances.
 type Method int
 
 // The available methods for computing linkage.
@@ -45,28 +78,83 @@ const (
 	MethodWard
 	MethodCentroid
 	MethodMedian
+	// MethodFlexible is flexible-beta linkage, which is parameterized by
+	// the Beta field of LinkageParams. Since it cannot be used without its
+	// parameter, it is only supported by LinkageWithParams64.
+	MethodFlexible
 )
 
-// enum converts a Go method value into a C enum method value.
-func (m Method) enum() C.kodama_method {
+// Valid returns true if and only if m is one of the methods defined by this
+// package.
+//
+// Every function that clusters checks its method up front, and either
+// returns an error or panics (for functions that do not return errors) when
+// the method is not valid. MethodFlexible is valid, but is rejected in the
+// same way by every function except LinkageWithParams64.
+func (m Method) Valid() bool {
+	return m >= MethodSingle && m <= MethodFlexible
+}
+
+// String returns the name of this method, e.g., "single" or "ward". If the
+// method is not valid, then it is formatted as "Method(n)".
+func (m Method) String() string {
 	switch m {
 	case MethodSingle:
-		return C.kodama_method_single
+		return "single"
 	case MethodComplete:
-		return C.kodama_method_complete
+		return "complete"
 	case MethodAverage:
-		return C.kodama_method_average
+		return "average"
 	case MethodWeighted:
-		return C.kodama_method_weighted
+		return "weighted"
 	case MethodWard:
-		return C.kodama_method_ward
+		return "ward"
 	case MethodCentroid:
-		return C.kodama_method_centroid
+		return "centroid"
 	case MethodMedian:
-		return C.kodama_method_median
+		return "median"
+	case MethodFlexible:
+		return "flexible"
 	default:
-		panic(fmt.Sprintf("unrecognized method: %v", m))
+		return fmt.Sprintf("Method(%d)", int(m))
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler. The method is encoded as
+// its name, as returned by String. An error is returned if the method is not
+// valid.
+func (m Method) MarshalText() ([]byte, error) {
+	if !m.Valid() {
+		return nil, fmt.Errorf("unrecognized method: %v", m)
+	}
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It accepts the name of
+// any valid method, as returned by String, ignoring case. This makes Method
+// usable directly as a configuration value, e.g., with flag.TextVar or as a
+// field in a JSON document.
+func (m *Method) UnmarshalText(text []byte) error {
+	for candidate := MethodSingle; candidate <= MethodFlexible; candidate++ {
+		if strings.EqualFold(string(text), candidate.String()) {
+			*m = candidate
+			return nil
+		}
 	}
+	return fmt.Errorf("unrecognized method name: %q", text)
+}
+
+// checkMethod returns an error if the given method is not valid, or if it
+// requires parameters and so cannot be used on its own.
+func checkMethod(m Method) error {
+	if !m.Valid() {
+		return fmt.Errorf("unrecognized method: %v", m)
+	}
+	if m == MethodFlexible {
+		return fmt.Errorf(
+			"%v linkage requires parameters, so it must be used with LinkageWithParams64", m)
+	}
+	return nil
 }
 
 // Dendrogram is a stepwise representation of a hierarchical clustering of
@@ -75,38 +163,250 @@ func (m Method) enum() C.kodama_method {
 // A dendrogram consists of a series of N - 1 steps, where N is the number
 // of observations that were clustered. Each step corresponds to the creation
 // of a new cluster by merging exactly two previous clusters.
+//
+// A dendrogram returned by clustering owns memory allocated by the C
+// library. That memory is released by Close or, failing that, when the
+// dendrogram is garbage collected. Every method checks that the dendrogram
+// is still usable before touching that memory, and panics with a
+// descriptive message instead of crashing the process if it is not. A
+// Dendrogram must not be copied by value.
+//
+// A dendrogram is never modified by clustering or by any method that reads
+// it, so it is safe for concurrent reads: any number of goroutines may call
+// methods such as Len, Observations and Steps on the same dendrogram at
+// once. The exceptions are Close, UnmarshalJSON and GobDecode, which replace
+// or release its contents and must not be called concurrently with any
+// other method.
 type Dendrogram struct {
-	p *C.kodama_dendrogram
+	// p is the C dendrogram produced by clustering. When p is nil, the
+	// dendrogram is instead backed entirely by Go memory in steps and
+	// observations, e.g., after being decoded.
+	p            *cDendrogram
+	steps        []Step
+	observations int
+	// closed is true once Close has been called.
+	closed bool
 }
 
 // newDendrogram creates a new dendrogram that wraps the C dendrogram.
 //
 // When the returned *Dendrogram is freed, then the C dendrogram should
 // also be freed automatically.
-func newDendrogram(cdend *C.kodama_dendrogram) *Dendrogram {
+func newDendrogram(cdend *cDendrogram) *Dendrogram {
 	dend := &Dendrogram{p: cdend}
 	runtime.SetFinalizer(dend, func(dend *Dendrogram) {
 		if dend.p != nil {
-			C.kodama_dendrogram_free(dend.p)
+			freeCDendrogram(dend.p)
 			dend.p = nil
 		}
 	})
 	return dend
 }
 
+// NewDendrogram creates a new dendrogram from the given steps, which may
+// have been computed elsewhere. The returned dendrogram is backed entirely
+// by Go memory and supports every method that a dendrogram returned by
+// clustering supports.
+//
+// The steps must form a consistent merge sequence for the given number of
+// observations. Namely, there must be exactly observations - 1 steps (or
+// none when there are no observations), where the ith step creates the
+// cluster with label observations + i. Each step must merge two distinct
+// clusters that exist before it and have not been merged by an earlier
+// step, its size must be the sum of the sizes of the merged clusters and
+// its dissimilarity must not be NaN. If any of these invariants are
+// violated, then an error is returned.
+//
+// The given steps are copied.
+func NewDendrogram(steps []Step, observations int) (*Dendrogram, error) {
+	if err := checkSteps(steps, observations); err != nil {
+		return nil, err
+	}
+	dend := &Dendrogram{}
+	dend.setSteps(append([]Step{}, steps...), observations)
+	return dend, nil
+}
+
+// ValidateSteps returns an error if the given steps are not a valid
+// dendrogram for the given number of observations. The error names the
+// index of the first offending step.
+//
+// The steps must satisfy every invariant documented on NewDendrogram. In
+// addition, the smaller label of each step must be assigned to Cluster1, per
+// the convention followed by every dendrogram produced by clustering.
+//
+// Dissimilarities are not required to be non-decreasing, since centroid and
+// median linkage may legitimately produce steps with smaller dissimilarities
+// than the steps beneath them.
+func ValidateSteps(steps []Step, observations int) error {
+	if err := checkSteps(steps, observations); err != nil {
+		return err
+	}
+	for i, s := range steps {
+		if s.Cluster1 > s.Cluster2 {
+			return fmt.Errorf(
+				"step %d: expected cluster1 < cluster2, but got %d > %d",
+				i, s.Cluster1, s.Cluster2)
+		}
+	}
+	return nil
+}
+
+// checkSteps returns an error if the given steps do not form a consistent
+// merge sequence, as documented on NewDendrogram.
+func checkSteps(steps []Step, observations int) error {
+	if err := checkPartialSteps(steps, observations); err != nil {
+		return err
+	}
+	if expectedLen := max(observations-1, 0); len(steps) != expectedLen {
+		return fmt.Errorf(
+			"expected %d steps for %d observations, but got %d",
+			expectedLen, observations, len(steps))
+	}
+	return nil
+}
+
+// checkPartialSteps is like checkSteps, except it also accepts the steps of
+// a partial dendrogram, e.g., as returned by Linkage64Until, which has fewer
+// than observations - 1 steps.
+func checkPartialSteps(steps []Step, observations int) error {
+	if observations < 0 {
+		return fmt.Errorf(
+			"expected non-negative number of observations, but got %d",
+			observations)
+	}
+	if maxLen := max(observations-1, 0); len(steps) > maxLen {
+		return fmt.Errorf(
+			"expected at most %d steps for %d observations, but got %d",
+			maxLen, observations, len(steps))
+	}
+
+	// sizes[label] is the size of the cluster with the given label, or 0
+	// if the cluster has already been merged.
+	sizes := make([]int, observations+len(steps))
+	for i := 0; i < observations; i++ {
+		sizes[i] = 1
+	}
+	for i, s := range steps {
+		label := observations + i
+		for _, c := range [2]int{s.Cluster1, s.Cluster2} {
+			if c < 0 || c >= label {
+				return fmt.Errorf(
+					"step %d: cluster label %d is not in range [0, %d)",
+					i, c, label)
+			}
+			if sizes[c] == 0 {
+				return fmt.Errorf(
+					"step %d: cluster %d was already merged", i, c)
+			}
+		}
+		if s.Cluster1 == s.Cluster2 {
+			return fmt.Errorf(
+				"step %d: cannot merge cluster %d with itself",
+				i, s.Cluster1)
+		}
+		if expected := sizes[s.Cluster1] + sizes[s.Cluster2]; s.Size != expected {
+			return fmt.Errorf(
+				"step %d: expected size %d, but got %d", i, expected, s.Size)
+		}
+		if math.IsNaN(s.Dissimilarity) {
+			return fmt.Errorf("step %d: dissimilarity is NaN", i)
+		}
+		sizes[label] = s.Size
+		sizes[s.Cluster1], sizes[s.Cluster2] = 0, 0
+	}
+	return nil
+}
+
+// setSteps replaces the contents of this dendrogram with the given steps,
+// such that it is backed entirely by Go memory. If this dendrogram wrapped
+// a C dendrogram, then the C dendrogram is freed.
+func (dend *Dendrogram) setSteps(steps []Step, observations int) {
+	if dend.p != nil {
+		freeCDendrogram(dend.p)
+		dend.p = nil
+	}
+	dend.steps = steps
+	dend.observations = observations
+	dend.closed = false
+}
+
+// Close releases the resources associated with this dendrogram
+// immediately, rather than waiting for the garbage collector to do so.
+//
+// This is useful when creating many dendrograms in a tight loop. Calling
+// Close more than once is safe and has no effect. Calling any other method
+// on a closed dendrogram panics.
+//
+// Close always returns nil. It is also safe to call on a nil *Dendrogram.
+func (dend *Dendrogram) Close() error {
+	if dend == nil || dend.closed {
+		return nil
+	}
+	if dend.p != nil {
+		freeCDendrogram(dend.p)
+		dend.p = nil
+		runtime.SetFinalizer(dend, nil)
+	}
+	dend.steps = nil
+	dend.observations = 0
+	dend.closed = true
+	return nil
+}
+
+// checkOpen panics if this dendrogram is nil or has been closed.
+//
+// Every method that reads the C dendrogram must call this first. Crashes in
+// C code bypass recover and take down the entire process, so it's better to
+// fail loudly here instead.
+func (dend *Dendrogram) checkOpen() {
+	if dend == nil {
+		panic("kodama: use of nil dendrogram")
+	}
+	if dend.closed {
+		panic("kodama: use of dendrogram after Close")
+	}
+}
+
 // Len returns the number of steps in this dendrogram.
 func (dend *Dendrogram) Len() int {
-	return int(C.kodama_dendrogram_len(dend.p))
+	dend.checkOpen()
+	if dend.p == nil {
+		return len(dend.steps)
+	}
+	n := int(cDendrogramLen(dend.p))
+	// Ensure the finalizer cannot free dend.p while C is still reading it.
+	runtime.KeepAlive(dend)
+	return n
 }
 
 // Observations returns the number of observations in the data that is
 // clustered by this dendrogram.
 func (dend *Dendrogram) Observations() int {
-	return int(C.kodama_dendrogram_observations(dend.p))
+	dend.checkOpen()
+	if dend.p == nil {
+		return dend.observations
+	}
+	n := int(cDendrogramObservations(dend.p))
+	runtime.KeepAlive(dend)
+	return n
 }
 
 // Steps returns a slice of steps that make up the given dendrogram.
 func (dend *Dendrogram) Steps() []Step {
+	return dend.AppendSteps(make([]Step, 0, dend.Len()))
+}
+
+// AppendSteps appends the steps that make up the given dendrogram to dst and
+// returns the extended slice.
+//
+// This is like Steps, except the caller can avoid an allocation by reusing
+// the capacity of dst, e.g., by passing steps[:0].
+func (dend *Dendrogram) AppendSteps(dst []Step) []Step {
+	dend.checkOpen()
+	if dend.p == nil {
+		return append(dst, dend.steps...)
+	}
 	len := dend.Len()
 	if len == 0 {
 		// Why do we special case the empty dendrogram? Well, it turns
@@ -114,21 +414,165 @@ func (dend *Dendrogram) Steps() []Step {
 		// Rust doesn't actually point to valid memory, and Go does not
 		// like this one bit. So avoid asking for the steps when we
 		// know they are empty.
-		return []Step{}
+		return dst
 	}
-	csteps := C.kodama_dendrogram_steps(dend.p)
-	gosteps := (*[math.MaxInt32]C.kodama_step)(unsafe.Pointer(csteps))[:len:len]
+	dst = appendCDendrogramSteps(dst, dend.p, len)
+	// The steps are read from memory owned by dend.p, so dend must not be
+	// finalized until they have all been copied.
+	runtime.KeepAlive(dend)
+	return dst
+}
 
-	steps := make([]Step, len)
-	for i, s := range gosteps {
-		steps[i] = Step{
-			Cluster1:      int(s.cluster1),
-			Cluster2:      int(s.cluster2),
-			Dissimilarity: float64(s.dissimilarity),
-			Size:          int(s.size),
+// All returns an iterator over the index and value of each step in this
+// dendrogram, in order.
+//
+// Each step is read directly from the underlying dendrogram as it is
+// yielded, so the steps are never copied into an intermediate slice, and
+// breaking out of the loop early avoids reading the remaining steps. The
+// dendrogram must not be closed while it is being iterated over, or else
+// the iterator panics.
+func (dend *Dendrogram) All() iter.Seq2[int, Step] {
+	return func(yield func(int, Step) bool) {
+		for i := 0; i < dend.Len(); i++ {
+			if !yield(i, dend.step(i)) {
+				return
+			}
 		}
 	}
-	return steps
+}
+
+// step returns the ith step of this dendrogram without copying any of the
+// other steps. It panics if i is out of range.
+func (dend *Dendrogram) step(i int) Step {
+	len := dend.Len()
+	if i < 0 || i >= len {
+		panic(fmt.Sprintf(
+			"kodama: step index %d out of range [0, %d)", i, len))
+	}
+	if dend.p == nil {
+		return dend.steps[i]
+	}
+	s := cDendrogramStep(dend.p, i)
+	runtime.KeepAlive(dend)
+	return s
+}
+
+// Root returns the last step of this dendrogram, which creates the cluster
+// containing every observation. Its dissimilarity is the height of the
+// entire tree.
+//
+// If this dendrogram has no steps, then the second return value is false.
+// For a partial dendrogram, e.g., as returned by Linkage64Until, the last
+// step only creates the root of one of its trees (see Roots).
+//
+// This runs in constant time and does not copy the other steps.
+func (dend *Dendrogram) Root() (Step, bool) {
+	len := dend.Len()
+	if len == 0 {
+		return Step{}, false
+	}
+	return dend.step(len - 1), true
+}
+
+// Roots returns the labels of the clusters that are never merged by a later
+// step, in ascending order. For a complete dendrogram of at least one
+// observation, this is the single cluster containing every observation. A
+// partial dendrogram, e.g., as returned by Linkage64Until, is a forest with
+// one root per tree. The returned slice is empty only when there are no
+// observations.
+func (dend *Dendrogram) Roots() []int {
+	obs := dend.Observations()
+	steps := dend.Steps()
+	merged := make([]bool, obs+len(steps))
+	for _, s := range steps {
+		merged[s.Cluster1], merged[s.Cluster2] = true, true
+	}
+	roots := make([]int, 0, obs-len(steps))
+	for label, ok := range merged {
+		if !ok {
+			roots = append(roots, label)
+		}
+	}
+	return roots
+}
+
+// NumTrees returns the number of trees in this dendrogram, which is the
+// length of Roots. This is 1 for a complete dendrogram of at least one
+// observation and 0 when there are no observations.
+func (dend *Dendrogram) NumTrees() int {
+	return dend.Observations() - dend.Len()
+}
+
+// ChildSizes returns the number of observations in each of the two clusters
+// merged by the given step, corresponding to its Cluster1 and Cluster2
+// fields, respectively.
+//
+// A cluster label less than Observations() refers to a single observation,
+// so its size is 1. Otherwise, the label refers to the cluster created by an
+// earlier step, and its size is that step's Size. The two sizes always sum to
+// the given step's Size.
+//
+// If stepIndex is not in the range [0, Len()), then this method panics.
+func (dend *Dendrogram) ChildSizes(stepIndex int) (size1, size2 int) {
+	obs := dend.Observations()
+	s := dend.step(stepIndex)
+	size := func(label int) int {
+		if label < obs {
+			return 1
+		}
+		return dend.step(label - obs).Size
+	}
+	return size(s.Cluster1), size(s.Cluster2)
+}
+
+// StepForCluster returns the index of the step that created the cluster with
+// the given label, such as the Cluster1 or Cluster2 field of a step.
+//
+// A label less than Observations() refers to a single observation, which was
+// not created by any step, so isLeaf is true and stepIndex is -1. Otherwise,
+// isLeaf is false and stepIndex is label - Observations().
+//
+// If label is not in the range [0, Observations() + Len()), then this
+// method panics.
+func (dend *Dendrogram) StepForCluster(label int) (stepIndex int, isLeaf bool) {
+	obs := dend.Observations()
+	if label < 0 || label >= obs+dend.Len() {
+		panic(fmt.Errorf(
+			"expected cluster label in range [0, %d), but got %d",
+			obs+dend.Len(), label))
+	}
+	if label < obs {
+		return -1, true
+	}
+	return label - obs, false
+}
+
+// Equal returns true if and only if this dendrogram and other have the same
+// number of observations and the same steps, where dissimilarities are
+// considered equal when the absolute value of their difference is less than
+// or equal to eps. (Infinite dissimilarities are only equal to an infinity
+// of the same sign.) Cluster labels and sizes must match exactly.
+//
+// Since cluster labels are compared exactly, both dendrograms must follow
+// the same convention for assigning labels to Cluster1 and Cluster2.
+// Dendrograms produced by clustering always assign the smaller label to
+// Cluster1, but dendrograms created by NewDendrogram may not.
+func (dend *Dendrogram) Equal(other *Dendrogram, eps float64) bool {
+	if dend.Observations() != other.Observations() || dend.Len() != other.Len() {
+		return false
+	}
+	steps1, steps2 := dend.Steps(), other.Steps()
+	for i := range steps1 {
+		s1, s2 := steps1[i], steps2[i]
+		if s1.Cluster1 != s2.Cluster1 || s1.Cluster2 != s2.Cluster2 || s1.Size != s2.Size {
+			return false
+		}
+		if s1.Dissimilarity != s2.Dissimilarity &&
+			!(math.Abs(s1.Dissimilarity-s2.Dissimilarity) <= eps) {
+			return false
+		}
+	}
+	return true
 }
 
 // Step is a single merge step in a dendrogram.
@@ -140,13 +584,21 @@ func (dend *Dendrogram) Steps() []Step {
 // `cluster1` field.
 type Step struct {
 	// The label corresponding to the first cluster.
-	Cluster1 int
+	Cluster1 int `json:"cluster1"`
 	// The label corresponding to the second cluster.
-	Cluster2 int
+	Cluster2 int `json:"cluster2"`
 	// The dissimilarity between cluster1 and cluster2.
-	Dissimilarity float64
+	Dissimilarity float64 `json:"dissimilarity"`
 	// The total number of observations in this merged cluster.
-	Size int
+	Size int `json:"size"`
+}
+
+// String returns a compact description of this step for logging, e.g.,
+// "merge(2,4) d=3.124 size=2". The dissimilarity is rounded to four
+// significant digits.
+func (s Step) String() string {
+	return fmt.Sprintf("merge(%d,%d) d=%.4g size=%d",
+		s.Cluster1, s.Cluster2, s.Dissimilarity, s.Size)
 }
 
 // Linkage64 returns a hierarchical clustering of observations given their
@@ -168,33 +620,331 @@ type Step struct {
 // corresponds to the creation of a cluster by merging exactly two previous
 // clusters. The very last cluster created contains all observations.
 //
-// If the length of the given matrix is not consistent with the number of
-// observations, then this function will panic.
+// If the method is not valid or the length of the given matrix is not
+// consistent with the number of observations, then this function will
+// panic. This function does not check that the dissimilarities are finite,
+// which avoids a scan over the matrix. Callers handling untrusted input
+// should prefer Linkage64E, which performs all of these checks and reports
+// failures as errors instead.
 //
-// The given matrix is never copied, but its values may be mutated during
-// clustering.
+// For performance, the given matrix is never copied and is used as scratch
+// space during clustering, so its values are mutated in place. Callers that
+// need to reuse the matrix after clustering should use Linkage64Copy
+// instead.
 func Linkage64(
 	condensedDissimilarityMatrix []float64,
 	observations int,
 	method Method,
 ) *Dendrogram {
-	expectedLen := (observations * (observations - 1)) / 2
-	if len(condensedDissimilarityMatrix) != expectedLen {
-		panic(fmt.Errorf(
-			"expected dissimilarity matrix of length %d, but got %d",
-			expectedLen, len(condensedDissimilarityMatrix)))
+	err := checkLinkageArgs(len(condensedDissimilarityMatrix), observations, method)
+	if err != nil {
+		panic(err)
+	}
+	return linkage64(condensedDissimilarityMatrix, observations, method)
+}
+
+// Linkage64E is like Linkage64, except it returns an error instead of
+// panicking when its input is invalid.
+//
+// An error is returned if the method is not valid, if the number of
+// observations is negative, if the length of the given matrix is not
+// consistent with the number of observations or if any dissimilarity in the
+// matrix is NaN or infinite. In the latter case, the error names the first
+// offending index along with the pair of observations it corresponds to.
+//
+// This is the recommended way to cluster dissimilarities that come from an
+// untrusted source.
+func Linkage64E(
+	condensedDissimilarityMatrix []float64,
+	observations int,
+	method Method,
+) (*Dendrogram, error) {
+	err := checkLinkageArgs(len(condensedDissimilarityMatrix), observations, method)
+	if err != nil {
+		return nil, err
+	}
+	err = checkFinite(condensedDissimilarityMatrix, observations)
+	if err != nil {
+		return nil, err
+	}
+	return linkage64(condensedDissimilarityMatrix, observations, method), nil
+}
+
+// Linkage64Copy is like Linkage64, except it clusters a copy of the given
+// matrix, so that the caller's matrix is never mutated.
+//
+// This costs an extra allocation the size of the matrix. Like Linkage64, it
+// panics if the method is not valid or the length of the given matrix is not
+// consistent with the number of observations.
+func Linkage64Copy(
+	condensedDissimilarityMatrix []float64,
+	observations int,
+	method Method,
+) *Dendrogram {
+	err := checkLinkageArgs(len(condensedDissimilarityMatrix), observations, method)
+	if err != nil {
+		panic(err)
+	}
+	matrix := make([]float64, len(condensedDissimilarityMatrix))
+	copy(matrix, condensedDissimilarityMatrix)
+	return linkage64(matrix, observations, method)
+}
+
+// LinkageCondensed is like Linkage64, except the dissimilarities and number
+// of observations are given together as a Condensed matrix. Like Linkage64,
+// it mutates c.Data in place and panics if the method is not valid or the
+// matrix was not constructed consistently (see NewCondensed).
+func LinkageCondensed(c Condensed, method Method) *Dendrogram {
+	return Linkage64(c.Data, c.Observations, method)
+}
+
+// LinkageNoFinalizer64 is like Linkage64, except the returned dendrogram has
+// no finalizer. Instead, its memory is released by calling the returned free
+// function, which is equivalent to calling Close on the dendrogram.
+//
+// Registering a finalizer has a small cost, and finalizers add work for the
+// garbage collector. This is a performance escape hatch for callers that
+// create and immediately discard a very large number of dendrograms. Most
+// callers should use Linkage64 and, if needed, Close instead.
+//
+// Callers must call free exactly when they are done with the dendrogram.
+// Forgetting to call it leaks the memory allocated by the C library, since
+// the garbage collector will never release it. Calling free more than once
+// is safe, and using the dendrogram after calling free panics.
+func LinkageNoFinalizer64(
+	condensedDissimilarityMatrix []float64,
+	observations int,
+	method Method,
+) (dend *Dendrogram, free func()) {
+	err := checkLinkageArgs(len(condensedDissimilarityMatrix), observations, method)
+	if err != nil {
+		panic(err)
+	}
+	dend = linkageNoFinalizer64(condensedDissimilarityMatrix, observations, method)
+	return dend, func() { dend.Close() }
+}
+
+// LinkageSquare64 returns a hierarchical clustering of observations given
+// their pairwise dissimilarities as a full square matrix.
+//
+// The matrix must be given in row-major order, such that the dissimilarity
+// between observations i and j is square[i*observations+j]. It must be
+// symmetric and have zeros along its diagonal, up to a small relative
+// tolerance, or else a descriptive error is returned. Only the upper
+// triangle is used for clustering.
+//
+// The square matrix is converted into a newly allocated condensed matrix
+// with ToCondensed before clustering, so it is never mutated. Beyond this,
+// LinkageSquare64 behaves like Linkage64E.
+func LinkageSquare64(
+	square []float64,
+	observations int,
+	method Method,
+) (*Dendrogram, error) {
+	condensed, err := ToCondensed(square, observations)
+	if err != nil {
+		return nil, err
 	}
+	return Linkage64E(condensed, observations, method)
+}
 
-	// Since we are reading this matrix (which is in Go memory) from
-	// Rust, and since we are explicitly allowing zero-length slices, we
-	// must ensure that we pass a non-null pointer to Rust. (If the Rust
-	// bindings allowed a null pointer, then we'd wind up with UB.)
-	if condensedDissimilarityMatrix == nil {
-		condensedDissimilarityMatrix = []float64{}
+// Linkage64Progress is like Linkage64, except it reports progress by calling
+// onProgress with the number of merges completed so far and the total number
+// of merges, which is observations - 1 (or 0 when there are no
+// observations).
+//
+// The C library does not report progress while it clusters, so clustering
+// is always done by the pure Go port of its algorithms, even when cgo is
+// available. (See the "Pure Go" section of the package documentation.)
+// This produces the same dendrogram as Linkage64, but is slower. onProgress
+// is called once with zero completed merges before clustering starts and
+// then after every merge, so the last call reports every merge as
+// completed.
+//
+// onProgress is always called synchronously from the goroutine that called
+// Linkage64Progress, so it may safely do anything, including call other
+// functions in this package, except read or modify the given matrix, which
+// is in use as scratch space.
+func Linkage64Progress(
+	condensedDissimilarityMatrix []float64,
+	observations int,
+	method Method,
+	onProgress func(completed, total int),
+) *Dendrogram {
+	err := checkLinkageArgs(len(condensedDissimilarityMatrix), observations, method)
+	if err != nil {
+		panic(err)
+	}
+	total := 0
+	if observations > 0 {
+		total = observations - 1
 	}
-	header := (*reflect.SliceHeader)(unsafe.Pointer(&condensedDissimilarityMatrix))
-	cmat := (*C.double)(unsafe.Pointer(header.Data))
-	return newDendrogram(C.kodama_linkage_double(cmat, C.size_t(observations), method.enum()))
+	onProgress(0, total)
+	steps := goLinkageParams(
+		condensedDissimilarityMatrix, observations, method, method.Algorithm(),
+		goParams{onMerge: func(completed int) {
+			onProgress(completed, total)
+		}})
+	dend := &Dendrogram{}
+	dend.setSteps(steps, observations)
+	return dend
+}
+
+// Linkage64Until is like Linkage64E, except it stops clustering before
+// applying the first step for which stop returns true, so the returned
+// dendrogram may be partial.
+//
+// The stop function is called with each step in order, exactly as it
+// appears in the dendrogram that Linkage64E would return, until it returns
+// true or every step has been applied. So it may stop once the next merge's
+// dissimilarity exceeds a limit, or once a cluster would exceed a size, for
+// example. It is never called while C code is running.
+//
+// A partial dendrogram still has Observations() equal to the given number
+// of observations, but Len() is the number of steps that were applied,
+// which is less than Observations() - 1. Steps, AppendSteps and All return
+// only those steps, and they are labeled as usual, so the cluster created
+// by the ith step still has label Observations() + i. The clusters that
+// were never merged further form a forest rather than a single tree.
+// FlatClusters and FlatClustersByCount treat the steps that were not
+// applied as never merging anything, so FlatClustersByCount may return more
+// than k clusters. Root only describes the last tree, so use Roots to find
+// the root of every tree instead. Methods that lay out or traverse the tree,
+// such as LeafOrder, Walk, Newick and SVG, cover every tree, while Tree
+// panics in favor of Trees. A partial dendrogram round trips through
+// MarshalJSON and GobEncode, but NewDendrogram and ValidateSteps reject its
+// steps.
+//
+// The C library has no way to stop early, so clustering runs to completion
+// and the dendrogram is truncated afterwards. This does not save time, but
+// it does produce exactly the steps that stopping early would. Like
+// Linkage64, the given matrix is used as scratch space and is mutated, and
+// the same errors as Linkage64E are returned.
+func Linkage64Until(
+	condensedDissimilarityMatrix []float64,
+	observations int,
+	method Method,
+	stop func(nextStep Step) bool,
+) (*Dendrogram, error) {
+	full, err := Linkage64E(condensedDissimilarityMatrix, observations, method)
+	if err != nil {
+		return nil, err
+	}
+	defer full.Close()
+	steps := full.Steps()
+	for i, s := range steps {
+		if stop(s) {
+			steps = steps[:i]
+			break
+		}
+	}
+	dend := &Dendrogram{}
+	dend.setSteps(steps, observations)
+	return dend, nil
+}
+
+// Linkage64Func returns a hierarchical clustering of the given number of
+// observations, where the dissimilarity between observations i and j is
+// computed on demand by calling dissim(i, j).
+//
+// The dissim function is called exactly once for every pair of observations
+// (i, j) where i < j, in condensed matrix order. It must return a finite
+// non-NaN dissimilarity, otherwise an error is returned. An error is also
+// returned if the method is not valid or the number of observations is
+// negative, in which case dissim is never called.
+//
+// Memory characteristics: the underlying clustering library requires a
+// dense condensed matrix, so this function still allocates one matrix of
+// observations-choose-2 float64 values (8 bytes each) and clustering uses
+// O(observations^2) memory at peak. What this function avoids is the need
+// for the caller to materialize its own copy of the dissimilarities, e.g.,
+// when they are computed or streamed from disk.
+func Linkage64Func(
+	observations int,
+	method Method,
+	dissim func(i, j int) float64,
+) (*Dendrogram, error) {
+	if err := checkMethod(method); err != nil {
+		return nil, err
+	}
+	if observations < 0 {
+		return nil, fmt.Errorf(
+			"expected non-negative number of observations, but got %d",
+			observations)
+	}
+	matrix := make([]float64, (observations*(observations-1))/2)
+	k := 0
+	for i := 0; i < observations; i++ {
+		for j := i + 1; j < observations; j++ {
+			matrix[k] = dissim(i, j)
+			k++
+		}
+	}
+	return Linkage64E(matrix, observations, method)
+}
+
+// LinkagePoints returns a hierarchical clustering of n points under an
+// arbitrary metric, where dist(i, j) returns the distance between points i
+// and j. This makes it easy to cluster things that are not vectors, e.g.,
+// strings under edit distance, by indexing into a slice of them in dist.
+//
+// LinkagePoints is equivalent to Linkage64Func. In particular, dist is
+// called exactly once for each of the n-choose-2 pairs (i, j) with i < j,
+// so it should be cheap, or else memoized by the caller if it is also
+// needed elsewhere. Its results are stored in a condensed matrix, which is
+// never passed back to the caller.
+func LinkagePoints(
+	n int,
+	method Method,
+	dist func(i, j int) float64,
+) (*Dendrogram, error) {
+	return Linkage64Func(n, method, dist)
+}
+
+// Linkage64Similarity returns a hierarchical clustering of observations
+// given their pairwise similarities, where higher values mean that
+// observations are closer, rather than dissimilarities.
+//
+// The similarities are given as a condensed matrix, laid out exactly as the
+// dissimilarities given to Linkage64 are. Each similarity is converted into
+// a dissimilarity by calling convert, which should be decreasing. If
+// convert is nil, then it is 1 - sim, which suits similarities in [0, 1]
+// such as cosine similarities or correlations. For similarities on another
+// scale, a typical choice is max - sim, where max is the largest possible
+// (or observed) similarity. The converted dissimilarities are clustered as
+// if by Linkage64E. Forgetting this conversion and clustering similarities
+// directly produces nonsense, since the most similar observations would be
+// merged last.
+//
+// The given matrix is never mutated. In addition to the errors returned by
+// Linkage64E, an error is returned if any converted dissimilarity is
+// negative, naming the offending index along with the pair of observations
+// it corresponds to.
+func Linkage64Similarity(
+	similarity []float64,
+	observations int,
+	method Method,
+	convert func(sim float64) float64,
+) (*Dendrogram, error) {
+	err := checkLinkageArgs(len(similarity), observations, method)
+	if err != nil {
+		return nil, err
+	}
+	if convert == nil {
+		convert = func(sim float64) float64 { return 1 - sim }
+	}
+	matrix := make([]float64, len(similarity))
+	for i, sim := range similarity {
+		matrix[i] = convert(sim)
+		if matrix[i] < 0 {
+			row, column := condensedPair(observations, i)
+			return nil, fmt.Errorf(
+				"dissimilarity converted from similarity %v at condensed "+
+					"index %d (pair %d,%d) is negative: %v",
+				sim, i, row, column, matrix[i])
+		}
+	}
+	return Linkage64E(matrix, observations, method)
 }
 
 // Linkage32 returns a hierarchical clustering of observations given their
@@ -216,8 +966,12 @@ func Linkage64(
 // corresponds to the creation of a cluster by merging exactly two previous
 // clusters. The very last cluster created contains all observations.
 //
-// If the length of the given matrix is not consistent with the number of
-// observations, then this function will panic.
+// If the method is not valid or the length of the given matrix is not
+// consistent with the number of observations, then this function will
+// panic. This function does not check that the dissimilarities are finite,
+// which avoids a scan over the matrix. Callers handling untrusted input
+// should prefer Linkage32E, which performs all of these checks and reports
+// failures as errors instead.
 //
 // The given matrix is never copied, but its values may be mutated during
 // clustering.
@@ -226,21 +980,110 @@ func Linkage32(
 	observations int,
 	method Method,
 ) *Dendrogram {
+	err := checkLinkageArgs(len(condensedDissimilarityMatrix), observations, method)
+	if err != nil {
+		panic(err)
+	}
+	return linkage32(condensedDissimilarityMatrix, observations, method)
+}
+
+// Linkage32E is like Linkage32, except it returns an error instead of
+// panicking when its input is invalid.
+//
+// An error is returned if the method is not valid, if the number of
+// observations is negative, if the length of the given matrix is not
+// consistent with the number of observations or if any dissimilarity in the
+// matrix is NaN or infinite. In the latter case, the error names the first
+// offending index along with the pair of observations it corresponds to.
+//
+// This is the recommended way to cluster dissimilarities that come from an
+// untrusted source.
+func Linkage32E(
+	condensedDissimilarityMatrix []float32,
+	observations int,
+	method Method,
+) (*Dendrogram, error) {
+	err := checkLinkageArgs(len(condensedDissimilarityMatrix), observations, method)
+	if err != nil {
+		return nil, err
+	}
+	err = checkFinite(condensedDissimilarityMatrix, observations)
+	if err != nil {
+		return nil, err
+	}
+	return linkage32(condensedDissimilarityMatrix, observations, method), nil
+}
+
+// Linkage returns a hierarchical clustering of observations given their
+// pairwise dissimilarities as either single or double precision floating
+// point numbers.
+//
+// This is a generic version of Linkage32 and Linkage64, and dispatches to
+// the appropriate one based on the width of T. It has exactly the same
+// requirements and behavior as those functions, including panicking when
+// the method is not valid or the length of the given matrix is not
+// consistent with the number of observations, and possibly mutating the
+// given matrix.
+func Linkage[T ~float32 | ~float64](
+	condensedDissimilarityMatrix []T,
+	observations int,
+	method Method,
+) *Dendrogram {
+	// Both float32 and float64 (and any types derived from them) have the
+	// same representation as their underlying type, so we can reinterpret
+	// the slice without copying it.
+	var zero T
+	data := unsafe.Pointer(unsafe.SliceData(condensedDissimilarityMatrix))
+	n := len(condensedDissimilarityMatrix)
+	if unsafe.Sizeof(zero) == 4 {
+		return Linkage32(unsafe.Slice((*float32)(data), n), observations, method)
+	}
+	return Linkage64(unsafe.Slice((*float64)(data), n), observations, method)
+}
+
+// checkLinkageArgs returns an error if the given method is not valid or if
+// the given length of a condensed dissimilarity matrix is not consistent
+// with the number of observations.
+func checkLinkageArgs(matrixLen, observations int, method Method) error {
+	if err := checkMethod(method); err != nil {
+		return err
+	}
+	return checkMatrixLen(matrixLen, observations)
+}
+
+// checkMatrixLen returns an error if the given length of a condensed
+// dissimilarity matrix is not consistent with the number of observations.
+func checkMatrixLen(matrixLen, observations int) error {
+	if observations < 0 {
+		return fmt.Errorf(
+			"expected non-negative number of observations, but got %d",
+			observations)
+	}
 	expectedLen := (observations * (observations - 1)) / 2
-	if len(condensedDissimilarityMatrix) != expectedLen {
-		panic(fmt.Errorf(
+	if matrixLen != expectedLen {
+		return fmt.Errorf(
 			"expected dissimilarity matrix of length %d, but got %d",
-			expectedLen, len(condensedDissimilarityMatrix)))
+			expectedLen, matrixLen)
 	}
+	return nil
+}
 
-	// Since we are reading this matrix (which is in Go memory) from
-	// Rust, and since we are explicitly allowing zero-length slices, we
-	// must ensure that we pass a non-null pointer to Rust. (If the Rust
-	// bindings allowed a null pointer, then we'd wind up with UB.)
-	if condensedDissimilarityMatrix == nil {
-		condensedDissimilarityMatrix = []float32{}
+// checkFinite returns an error if any dissimilarity in the given condensed
+// matrix is NaN or infinite. The error names the first offending index and
+// the pair of observations it corresponds to.
+func checkFinite[T float32 | float64](
+	condensedDissimilarityMatrix []T,
+	observations int,
+) error {
+	for i, x := range condensedDissimilarityMatrix {
+		f := float64(x)
+		if !math.IsNaN(f) && !math.IsInf(f, 0) {
+			continue
+		}
+		row, column := condensedPair(observations, i)
+		return fmt.Errorf(
+			"dissimilarity at condensed index %d (pair %d,%d) is %v",
+			i, row, column, f)
 	}
-	header := (*reflect.SliceHeader)(unsafe.Pointer(&condensedDissimilarityMatrix))
-	cmat := (*C.float)(unsafe.Pointer(header.Data))
-	return newDendrogram(C.kodama_linkage_float(cmat, C.size_t(observations), method.enum()))
+	return nil
 }
@@ -0,0 +1,141 @@
+//go:build cgo && !kodama_purego
+
+package kodama
+
+// #cgo LDFLAGS: -lkodama
+// #include "kodama.h"
+import "C"
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"unsafe"
+)
+
+// cDendrogram is a dendrogram allocated by the C library.
+type cDendrogram = C.kodama_dendrogram
+
+// enum converts a Go method value into a C enum method value.
+func (m Method) enum() C.kodama_method {
+	switch m {
+	case MethodSingle:
+		return C.kodama_method_single
+	case MethodComplete:
+		return C.kodama_method_complete
+	case MethodAverage:
+		return C.kodama_method_average
+	case MethodWeighted:
+		return C.kodama_method_weighted
+	case MethodWard:
+		return C.kodama_method_ward
+	case MethodCentroid:
+		return C.kodama_method_centroid
+	case MethodMedian:
+		return C.kodama_method_median
+	default:
+		panic(fmt.Sprintf("unrecognized method: %v", m))
+	}
+}
+
+// freeCDendrogram frees the given C dendrogram, which must not be used
+// afterwards.
+func freeCDendrogram(p *cDendrogram) {
+	C.kodama_dendrogram_free(p)
+}
+
+// cDendrogramLen returns the number of steps in the given C dendrogram.
+func cDendrogramLen(p *cDendrogram) int {
+	return int(C.kodama_dendrogram_len(p))
+}
+
+// cDendrogramObservations returns the number of observations clustered by
+// the given C dendrogram.
+func cDendrogramObservations(p *cDendrogram) int {
+	return int(C.kodama_dendrogram_observations(p))
+}
+
+// appendCDendrogramSteps appends the first len steps of the given C
+// dendrogram to dst, where len must be positive.
+func appendCDendrogramSteps(dst []Step, p *cDendrogram, len int) []Step {
+	csteps := C.kodama_dendrogram_steps(p)
+	gosteps := (*[math.MaxInt32]C.kodama_step)(unsafe.Pointer(csteps))[:len:len]
+	for _, s := range gosteps {
+		dst = append(dst, goStep(s))
+	}
+	return dst
+}
+
+// cDendrogramStep returns the ith step of the given C dendrogram, which
+// must be in range.
+func cDendrogramStep(p *cDendrogram, i int) Step {
+	csteps := C.kodama_dendrogram_steps(p)
+	return goStep((*[math.MaxInt32]C.kodama_step)(unsafe.Pointer(csteps))[i])
+}
+
+// goStep converts a C step into a Go step.
+func goStep(s C.kodama_step) Step {
+	return Step{
+		Cluster1:      int(s.cluster1),
+		Cluster2:      int(s.cluster2),
+		Dissimilarity: float64(s.dissimilarity),
+		Size:          int(s.size),
+	}
+}
+
+// linkage64 clusters the given matrix without checking its length.
+func linkage64(
+	condensedDissimilarityMatrix []float64,
+	observations int,
+	method Method,
+) *Dendrogram {
+	return newDendrogram(clinkage64(condensedDissimilarityMatrix, observations, method))
+}
+
+// linkageNoFinalizer64 is like linkage64, except the returned dendrogram has
+// no finalizer.
+func linkageNoFinalizer64(
+	condensedDissimilarityMatrix []float64,
+	observations int,
+	method Method,
+) *Dendrogram {
+	return &Dendrogram{p: clinkage64(condensedDissimilarityMatrix, observations, method)}
+}
+
+// clinkage64 clusters the given matrix without checking its length, and
+// returns the C dendrogram without wrapping it. The caller is responsible
+// for freeing it.
+func clinkage64(
+	condensedDissimilarityMatrix []float64,
+	observations int,
+	method Method,
+) *C.kodama_dendrogram {
+	// Since we are reading this matrix (which is in Go memory) from
+	// Rust, and since we are explicitly allowing zero-length slices, we
+	// must ensure that we pass a non-null pointer to Rust. (If the Rust
+	// bindings allowed a null pointer, then we'd wind up with UB.)
+	if condensedDissimilarityMatrix == nil {
+		condensedDissimilarityMatrix = []float64{}
+	}
+	header := (*reflect.SliceHeader)(unsafe.Pointer(&condensedDissimilarityMatrix))
+	cmat := (*C.double)(unsafe.Pointer(header.Data))
+	return C.kodama_linkage_double(cmat, C.size_t(observations), method.enum())
+}
+
+// linkage32 clusters the given matrix without checking its length.
+func linkage32(
+	condensedDissimilarityMatrix []float32,
+	observations int,
+	method Method,
+) *Dendrogram {
+	// Since we are reading this matrix (which is in Go memory) from
+	// Rust, and since we are explicitly allowing zero-length slices, we
+	// must ensure that we pass a non-null pointer to Rust. (If the Rust
+	// bindings allowed a null pointer, then we'd wind up with UB.)
+	if condensedDissimilarityMatrix == nil {
+		condensedDissimilarityMatrix = []float32{}
+	}
+	header := (*reflect.SliceHeader)(unsafe.Pointer(&condensedDissimilarityMatrix))
+	cmat := (*C.float)(unsafe.Pointer(header.Data))
+	return newDendrogram(C.kodama_linkage_float(cmat, C.size_t(observations), method.enum()))
+}
@@ -0,0 +1,59 @@
+//go:build !cgo || kodama_purego
+
+package kodama
+
+// cDendrogram stands in for a dendrogram allocated by the C library. When
+// cgo is unavailable or this package is built with the kodama_purego build
+// tag, clustering is done by goLinkage instead, so a Dendrogram never wraps
+// a C dendrogram and its p field is always nil.
+type cDendrogram struct{}
+
+func freeCDendrogram(p *cDendrogram) {}
+
+func cDendrogramLen(p *cDendrogram) int {
+	panic("kodama: unreachable: C dendrogram in pure Go build")
+}
+
+func cDendrogramObservations(p *cDendrogram) int {
+	panic("kodama: unreachable: C dendrogram in pure Go build")
+}
+
+func appendCDendrogramSteps(dst []Step, p *cDendrogram, len int) []Step {
+	panic("kodama: unreachable: C dendrogram in pure Go build")
+}
+
+func cDendrogramStep(p *cDendrogram, i int) Step {
+	panic("kodama: unreachable: C dendrogram in pure Go build")
+}
+
+// linkage64 clusters the given matrix without checking its length.
+func linkage64(
+	condensedDissimilarityMatrix []float64,
+	observations int,
+	method Method,
+) *Dendrogram {
+	dend := &Dendrogram{}
+	dend.setSteps(goLinkage(condensedDissimilarityMatrix, observations, method), observations)
+	return dend
+}
+
+// linkageNoFinalizer64 is like linkage64. Since the returned dendrogram is
+// backed entirely by Go memory, it never has a finalizer.
+func linkageNoFinalizer64(
+	condensedDissimilarityMatrix []float64,
+	observations int,
+	method Method,
+) *Dendrogram {
+	return linkage64(condensedDissimilarityMatrix, observations, method)
+}
+
+// linkage32 clusters the given matrix without checking its length.
+func linkage32(
+	condensedDissimilarityMatrix []float32,
+	observations int,
+	method Method,
+) *Dendrogram {
+	dend := &Dendrogram{}
+	dend.setSteps(goLinkage(condensedDissimilarityMatrix, observations, method), observations)
+	return dend
+}
@@ -1,7 +1,14 @@
 package kodama
 
 import (
+	"encoding/json"
+	"fmt"
 	"math"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -38,6 +45,28 @@ var maSteps = []Step{
 	{0, 9, 25.589444117482433, 6},
 }
 
+// maDendrogram clusters a copy of the above dissimilarities using average
+// linkage.
+func maDendrogram() *Dendrogram {
+	dis := make([]float64, len(maCondensedMatrix64))
+	copy(dis, maCondensedMatrix64)
+	return Linkage64(dis, maObservations, MethodAverage)
+}
+
+// maPartialDendrogram returns the MA dendrogram stopped after its first two
+// steps, which merge {2, 4} and then {5, 2, 4}. Its trees are rooted at 0,
+// 1, 3 and 7.
+func maPartialDendrogram() *Dendrogram {
+	dis := append([]float64{}, maCondensedMatrix64...)
+	dend, err := Linkage64Until(dis, maObservations, MethodAverage, func(s Step) bool {
+		return s.Dissimilarity > 8
+	})
+	if err != nil {
+		panic(err)
+	}
+	return dend
+}
+
 func TestLinkage64(t *testing.T) {
 	dis := make([]float64, len(maCondensedMatrix64))
 	copy(dis, maCondensedMatrix64)
@@ -52,6 +81,131 @@ func TestLinkage64(t *testing.T) {
 	}
 }
 
+func TestLinkage64Copy(t *testing.T) {
+	dis := make([]float64, len(maCondensedMatrix64))
+	copy(dis, maCondensedMatrix64)
+
+	dend := Linkage64Copy(dis, maObservations, MethodAverage)
+	steps := dend.Steps()
+	for i := range steps {
+		assertStepApproxEq(t, i, steps[i], maSteps[i])
+	}
+	for i := range dis {
+		if dis[i] != maCondensedMatrix64[i] {
+			t.Fatalf("expected matrix to be unchanged at %d, but got %v (original %v)\n",
+				i, dis[i], maCondensedMatrix64[i])
+		}
+	}
+}
+
+func TestLinkageCondensed(t *testing.T) {
+	dis := make([]float64, len(maCondensedMatrix64))
+	copy(dis, maCondensedMatrix64)
+	c, err := NewCondensed(dis, maObservations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := LinkageCondensed(c, MethodAverage).Steps()
+	for i := range steps {
+		assertStepApproxEq(t, i, steps[i], maSteps[i])
+	}
+}
+
+func TestLinkage64WardMatchesSciPy(t *testing.T) {
+	// The observations 0, 1, 3 and 7 on a line. Given their raw Euclidean
+	// distances, Ward linkage should produce SciPy's merge heights, which
+	// are sqrt(2|A||B| / (|A| + |B|)) times the distance between the
+	// centroids of A and B.
+	points := []float64{0, 1, 3, 7}
+	dis, err := EuclideanCondensed(points, 4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dend := Linkage64(dis, 4, MethodWard)
+	expected := []Step{
+		{0, 1, 1, 2},
+		{2, 4, math.Sqrt(4.0/3.0) * 2.5, 3},
+		{3, 5, math.Sqrt(6.0/4.0) * (7 - 4.0/3.0), 4},
+	}
+	steps := dend.Steps()
+	for i := range steps {
+		assertStepApproxEq(t, i, steps[i], expected[i])
+	}
+}
+
+func TestLinkageDeterministicTies(t *testing.T) {
+	const obs = 8
+	rng := rand.New(rand.NewSource(1))
+	// Only use a few distinct dissimilarities, so that there are many ties.
+	tied := make([]float64, (obs*(obs-1))/2)
+	for i := range tied {
+		tied[i] = float64(1 + rng.Intn(3))
+	}
+	methods := []Method{
+		MethodSingle, MethodComplete, MethodAverage, MethodWeighted,
+		MethodWard, MethodCentroid, MethodMedian,
+	}
+	for _, method := range methods {
+		expected := Linkage64Copy(tied, obs, method).Steps()
+		for run := 0; run < 50; run++ {
+			got := Linkage64Copy(tied, obs, method).Steps()
+			if !reflect.DeepEqual(got, expected) {
+				t.Fatalf("method %d, run %d: expected %v, but got %v\n",
+					method, run, expected, got)
+			}
+		}
+	}
+
+	// Permuting the observations may change how ties are broken, but
+	// clustering the same permutation is still deterministic, and single
+	// linkage always merges at the same dissimilarities.
+	heights := func(steps []Step) []float64 {
+		hs := make([]float64, len(steps))
+		for i, s := range steps {
+			hs[i] = s.Dissimilarity
+		}
+		return hs
+	}
+	expectedHeights := heights(Linkage64Copy(tied, obs, MethodSingle).Steps())
+	for shuffle := 0; shuffle < 50; shuffle++ {
+		perm := rng.Perm(obs)
+		permuted := make([]float64, len(tied))
+		for i := 0; i < obs; i++ {
+			for j := i + 1; j < obs; j++ {
+				permuted[condensedIndex(obs, i, j)] = tied[CondensedIndex(obs, perm[i], perm[j])]
+			}
+		}
+		first := Linkage64Copy(permuted, obs, MethodSingle).Steps()
+		second := Linkage64Copy(permuted, obs, MethodSingle).Steps()
+		if !reflect.DeepEqual(first, second) {
+			t.Fatalf("shuffle %d: expected %v, but got %v\n", shuffle, first, second)
+		}
+		if got := heights(first); !reflect.DeepEqual(got, expectedHeights) {
+			t.Fatalf("shuffle %d: expected heights %v, but got %v\n",
+				shuffle, expectedHeights, got)
+		}
+	}
+}
+
+func TestLinkageNoFinalizer64(t *testing.T) {
+	dis := make([]float64, len(maCondensedMatrix64))
+	copy(dis, maCondensedMatrix64)
+
+	dend, free := LinkageNoFinalizer64(dis, maObservations, MethodAverage)
+	steps := dend.Steps()
+	for i := range steps {
+		assertStepApproxEq(t, i, steps[i], maSteps[i])
+	}
+	free()
+	free()
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic after free\n")
+		}
+	}()
+	dend.Len()
+}
+
 func TestLinkage32(t *testing.T) {
 	dis := make([]float32, len(maCondensedMatrix64))
 	for i, x := range maCondensedMatrix64 {
@@ -68,6 +222,35 @@ func TestLinkage32(t *testing.T) {
 	}
 }
 
+func TestLinkageGeneric(t *testing.T) {
+	type distance float32
+
+	dis64 := make([]float64, len(maCondensedMatrix64))
+	copy(dis64, maCondensedMatrix64)
+	dis32 := make([]distance, len(maCondensedMatrix64))
+	for i, x := range maCondensedMatrix64 {
+		dis32[i] = distance(x)
+	}
+
+	for _, dend := range []*Dendrogram{
+		Linkage(dis64, maObservations, MethodAverage),
+		Linkage(dis32, maObservations, MethodAverage),
+	} {
+		steps := dend.Steps()
+		if len(steps) != len(maSteps) {
+			t.Fatalf("expected %d steps, but got %d\n", len(maSteps), len(steps))
+		}
+		for i := range steps {
+			assertStepApproxEq(t, i, steps[i], maSteps[i])
+		}
+	}
+
+	var empty []float32
+	if dend := Linkage(empty, 0, MethodAverage); dend.Len() != 0 {
+		t.Fatalf("expected empty dendrogram, but got one of length %d\n", dend.Len())
+	}
+}
+
 func TestLinkage64Empty(t *testing.T) {
 	// nil slice
 	var dis []float64
@@ -134,6 +317,317 @@ func TestLinkage32EmptySteps(t *testing.T) {
 	}
 }
 
+func TestLinkage64E(t *testing.T) {
+	dis := make([]float64, len(maCondensedMatrix64))
+	copy(dis, maCondensedMatrix64)
+
+	dend, err := Linkage64E(dis, maObservations, MethodAverage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := dend.Steps()
+	for i := range steps {
+		assertStepApproxEq(t, i, steps[i], maSteps[i])
+	}
+}
+
+func TestLinkage64EInvalid(t *testing.T) {
+	if _, err := Linkage64E(maCondensedMatrix64, maObservations+1, MethodAverage); err == nil {
+		t.Fatal("expected error for mismatched matrix length")
+	}
+	if _, err := Linkage64E([]float64{1}, -1, MethodAverage); err == nil {
+		t.Fatal("expected error for negative observations")
+	}
+	for _, x := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		dis := make([]float64, len(maCondensedMatrix64))
+		copy(dis, maCondensedMatrix64)
+		dis[7] = x
+		if _, err := Linkage64E(dis, maObservations, MethodAverage); err == nil {
+			t.Fatalf("expected error for dissimilarity %v\n", x)
+		}
+	}
+}
+
+func TestMethodValid(t *testing.T) {
+	names := map[Method]string{
+		MethodSingle:   "single",
+		MethodComplete: "complete",
+		MethodAverage:  "average",
+		MethodWeighted: "weighted",
+		MethodWard:     "ward",
+		MethodCentroid: "centroid",
+		MethodMedian:   "median",
+		MethodFlexible: "flexible",
+	}
+	for method, name := range names {
+		if !method.Valid() {
+			t.Fatalf("expected %s to be valid\n", name)
+		}
+		if got := method.String(); got != name {
+			t.Fatalf("expected %q, but got %q\n", name, got)
+		}
+	}
+	for _, method := range []Method{-1, MethodFlexible + 1} {
+		if method.Valid() {
+			t.Fatalf("expected %d to be invalid\n", int(method))
+		}
+		if expected := fmt.Sprintf("Method(%d)", int(method)); method.String() != expected {
+			t.Fatalf("expected %q, but got %q\n", expected, method.String())
+		}
+	}
+}
+
+func TestMethodText(t *testing.T) {
+	for method := MethodSingle; method <= MethodFlexible; method++ {
+		text, err := method.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Method
+		if err := got.UnmarshalText(text); err != nil {
+			t.Fatal(err)
+		}
+		if got != method {
+			t.Fatalf("expected %v, but got %v\n", method, got)
+		}
+	}
+
+	var m Method
+	if err := m.UnmarshalText([]byte("WaRd")); err != nil || m != MethodWard {
+		t.Fatalf("expected ward, but got %v (err: %v)\n", m, err)
+	}
+	for _, bad := range []string{"", "wards", "upgma"} {
+		if err := m.UnmarshalText([]byte(bad)); err == nil {
+			t.Fatalf("expected error for %q\n", bad)
+		}
+	}
+	if _, err := (MethodFlexible + 1).MarshalText(); err == nil {
+		t.Fatalf("expected error for invalid method\n")
+	}
+
+	var config struct {
+		Method Method `json:"method"`
+	}
+	if err := json.Unmarshal([]byte(`{"method": "Complete"}`), &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Method != MethodComplete {
+		t.Fatalf("expected complete, but got %v\n", config.Method)
+	}
+	encoded, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"method":"complete"}`; string(encoded) != expected {
+		t.Fatalf("expected %s, but got %s\n", expected, encoded)
+	}
+}
+
+func TestStepString(t *testing.T) {
+	tests := map[string]Step{
+		"merge(2,4) d=3.124 size=2":   maSteps[0],
+		"merge(0,9) d=25.59 size=6":   maSteps[4],
+		"merge(1,3) d=+Inf size=4":    {1, 3, math.Inf(1), 4},
+		"merge(0,1) d=1.5e+06 size=2": {0, 1, 1.5e6, 2},
+	}
+	for expected, s := range tests {
+		if got := s.String(); got != expected {
+			t.Fatalf("expected %q, but got %q\n", expected, got)
+		}
+	}
+}
+
+func TestInvalidMethod(t *testing.T) {
+	invalid := MethodFlexible + 1
+	dis := func() []float64 {
+		return append([]float64{}, maCondensedMatrix64...)
+	}
+	errs := map[string]func() error{
+		"Linkage64E": func() error {
+			_, err := Linkage64E(dis(), maObservations, invalid)
+			return err
+		},
+		"Linkage32E": func() error {
+			_, err := Linkage32E([]float32{1}, 2, invalid)
+			return err
+		},
+		"Linkage64Func": func() error {
+			_, err := Linkage64Func(2, invalid, func(i, j int) float64 {
+				t.Fatalf("expected dissim to never be called\n")
+				return 0
+			})
+			return err
+		},
+		"LinkageSparse64": func() error {
+			_, err := LinkageSparse64(nil, 2, invalid, math.Inf(1))
+			return err
+		},
+		"LinkageWeighted64": func() error {
+			_, err := LinkageWeighted64([]float64{1}, 2, []int{1, 1}, invalid)
+			return err
+		},
+	}
+	for name, f := range errs {
+		err := f()
+		if err == nil || !strings.Contains(err.Error(), "unrecognized method") {
+			t.Fatalf("%s: expected unrecognized method error, but got %v\n", name, err)
+		}
+	}
+
+	panics := map[string]func(){
+		"Linkage64": func() { Linkage64(dis(), maObservations, invalid) },
+		"Linkage32": func() { Linkage32([]float32{1}, 2, invalid) },
+		"Linkage16": func() { Linkage16([]uint16{0x3C00}, 2, invalid) },
+		"Linkage":   func() { Linkage(dis(), maObservations, invalid) },
+	}
+	for name, f := range panics {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("%s: expected panic\n", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
+
+func TestLinkage64ENaNMessage(t *testing.T) {
+	dis := make([]float64, len(maCondensedMatrix64))
+	copy(dis, maCondensedMatrix64)
+	dis[11] = math.NaN()
+	_, err := Linkage64E(dis, maObservations, MethodAverage)
+	if err == nil {
+		t.Fatal("expected error for NaN dissimilarity")
+	}
+	expected := "dissimilarity at condensed index 11 (pair 2,5) is NaN"
+	if err.Error() != expected {
+		t.Fatalf("expected error %q, but got %q\n", expected, err.Error())
+	}
+}
+
+func TestLinkageSquare64(t *testing.T) {
+	square := make([]float64, maObservations*maObservations)
+	for i := 0; i < maObservations; i++ {
+		for j := i + 1; j < maObservations; j++ {
+			d := maCondensedMatrix64[CondensedIndex(maObservations, i, j)]
+			square[i*maObservations+j] = d
+			square[j*maObservations+i] = d
+		}
+	}
+	dend, err := LinkageSquare64(square, maObservations, MethodAverage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := dend.Steps()
+	for i := range steps {
+		assertStepApproxEq(t, i, steps[i], maSteps[i])
+	}
+
+	asymmetric := append([]float64{}, square...)
+	asymmetric[1*maObservations+3] += 1
+	if _, err := LinkageSquare64(asymmetric, maObservations, MethodAverage); err == nil {
+		t.Fatal("expected error for asymmetric matrix")
+	}
+	diagonal := append([]float64{}, square...)
+	diagonal[2*maObservations+2] = 1
+	if _, err := LinkageSquare64(diagonal, maObservations, MethodAverage); err == nil {
+		t.Fatal("expected error for non-zero diagonal")
+	}
+	if _, err := LinkageSquare64(square[1:], maObservations, MethodAverage); err == nil {
+		t.Fatal("expected error for mismatched matrix length")
+	}
+}
+
+func TestLinkage64Progress(t *testing.T) {
+	dis := make([]float64, len(maCondensedMatrix64))
+	copy(dis, maCondensedMatrix64)
+
+	last, calls := -1, 0
+	dend := Linkage64Progress(dis, maObservations, MethodAverage, func(completed, total int) {
+		calls++
+		if total != maObservations-1 {
+			t.Fatalf("expected total of %d, but got %d\n", maObservations-1, total)
+		}
+		if last == -1 && completed != 0 {
+			t.Fatalf("expected first progress of 0, but got %d\n", completed)
+		}
+		if completed < last || completed > total {
+			t.Fatalf("unexpected progress %d/%d after %d\n", completed, total, last)
+		}
+		last = completed
+	})
+	if last != maObservations-1 {
+		t.Fatalf("expected final progress of %d, but got %d\n", maObservations-1, last)
+	}
+	// Progress is reported once before clustering and after every merge.
+	if calls != maObservations {
+		t.Fatalf("expected %d calls, but got %d\n", maObservations, calls)
+	}
+	if expected := maDendrogram(); !dend.Equal(expected, 0) {
+		t.Fatalf("expected %v, but got %v\n", expected.Steps(), dend.Steps())
+	}
+}
+
+func TestLinkage64Func(t *testing.T) {
+	dend, err := Linkage64Func(maObservations, MethodAverage, func(i, j int) float64 {
+		return maCondensedMatrix64[CondensedIndex(maObservations, i, j)]
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := dend.Steps()
+	for i := range steps {
+		assertStepApproxEq(t, i, steps[i], maSteps[i])
+	}
+
+	_, err = Linkage64Func(3, MethodAverage, func(i, j int) float64 {
+		return math.Inf(1)
+	})
+	if err == nil {
+		t.Fatal("expected error for infinite dissimilarity")
+	}
+}
+
+func TestLinkagePoints(t *testing.T) {
+	// Cluster words by the difference in their lengths, which is a metric
+	// on strings that are not vectors.
+	words := []string{"a", "bb", "cccccc", "ddddddd", "ee"}
+	calls := 0
+	dend, err := LinkagePoints(len(words), MethodSingle, func(i, j int) float64 {
+		calls++
+		return math.Abs(float64(len(words[i]) - len(words[j])))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := len(words) * (len(words) - 1) / 2; calls != expected {
+		t.Fatalf("expected %d calls, but got %d\n", expected, calls)
+	}
+	expected := []Step{
+		{1, 4, 0, 2},
+		{0, 5, 1, 3},
+		{2, 3, 1, 2},
+		{6, 7, 4, 5},
+	}
+	steps := dend.Steps()
+	for i := range steps {
+		assertStepApproxEq(t, i, steps[i], expected[i])
+	}
+}
+
+func TestLinkage32EInvalid(t *testing.T) {
+	if _, err := Linkage32E([]float32{1, 2}, 3, MethodAverage); err == nil {
+		t.Fatal("expected error for mismatched matrix length")
+	}
+	if _, err := Linkage32E([]float32{float32(math.NaN())}, 2, MethodAverage); err == nil {
+		t.Fatal("expected error for NaN dissimilarity")
+	}
+	if _, err := Linkage32E([]float32{}, 0, MethodAverage); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func assertStepApproxEq(t *testing.T, stepIndex int, got, expected Step) {
 	eps := 0.000001
 	if math.Abs(got.Dissimilarity-expected.Dissimilarity) > eps {
@@ -157,3 +651,565 @@ func assertStepApproxEq(t *testing.T, stepIndex int, got, expected Step) {
 			stepIndex, got.Size, expected.Size)
 	}
 }
+
+func TestNewDendrogram(t *testing.T) {
+	dend, err := NewDendrogram(maSteps, maObservations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dend.Observations() != maObservations {
+		t.Fatalf("expected %d observations, but got %d\n",
+			maObservations, dend.Observations())
+	}
+	steps := dend.Steps()
+	if len(steps) != len(maSteps) {
+		t.Fatalf("expected %d steps, but got %d\n", len(maSteps), len(steps))
+	}
+	for i := range steps {
+		assertStepApproxEq(t, i, steps[i], maSteps[i])
+	}
+
+	// Mutating the returned steps should not affect the dendrogram.
+	steps[0].Size = 100
+	if dend.Steps()[0].Size != 2 {
+		t.Fatal("expected Steps to return a copy")
+	}
+}
+
+func TestNewDendrogramEmpty(t *testing.T) {
+	for _, obs := range []int{0, 1} {
+		dend, err := NewDendrogram(nil, obs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dend.Len() != 0 || len(dend.Steps()) != 0 {
+			t.Fatalf("expected empty dendrogram, but got %v\n", dend.Steps())
+		}
+	}
+}
+
+func TestNewDendrogramInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []Step
+		obs   int
+	}{
+		{"negative observations", nil, -1},
+		{"too few steps", []Step{{0, 1, 1, 2}}, 3},
+		{"label out of range", []Step{{0, 3, 1, 2}, {2, 4, 2, 3}}, 3},
+		{"label merged twice", []Step{{0, 1, 1, 2}, {0, 2, 2, 2}}, 3},
+		{"self merge", []Step{{1, 1, 1, 2}, {0, 3, 2, 3}}, 3},
+		{"bad size", []Step{{0, 1, 1, 2}, {2, 3, 2, 4}}, 3},
+		{"NaN", []Step{{0, 1, math.NaN(), 2}, {2, 3, 2, 3}}, 3},
+	}
+	for _, test := range tests {
+		if _, err := NewDendrogram(test.steps, test.obs); err == nil {
+			t.Fatalf("%s: expected error\n", test.name)
+		}
+	}
+}
+
+func TestAppendSteps(t *testing.T) {
+	dend := maDendrogram()
+	buf := make([]Step, 1, 16)
+	got := dend.AppendSteps(buf)
+	if len(got) != 1+len(maSteps) || &got[0] != &buf[0] {
+		t.Fatalf("expected steps appended in place, but got %v\n", got)
+	}
+	for i := range maSteps {
+		assertStepApproxEq(t, i, got[i+1], maSteps[i])
+	}
+
+	allocs := testing.AllocsPerRun(10, func() {
+		buf = dend.AppendSteps(buf[:0])
+	})
+	if allocs != 0 {
+		t.Fatalf("expected no allocations, but got %f\n", allocs)
+	}
+
+	// Empty dendrograms still return a non-nil slice from Steps.
+	if steps := Linkage64([]float64{}, 0, MethodAverage).Steps(); steps == nil {
+		t.Fatal("expected non-nil steps")
+	}
+}
+
+func TestRoot(t *testing.T) {
+	root, ok := maDendrogram().Root()
+	if !ok {
+		t.Fatal("expected root step")
+	}
+	assertStepApproxEq(t, len(maSteps)-1, root, maSteps[len(maSteps)-1])
+
+	if _, ok := Linkage64([]float64{}, 1, MethodAverage).Root(); ok {
+		t.Fatal("expected no root step for empty dendrogram")
+	}
+}
+
+func TestAll(t *testing.T) {
+	goDend, err := NewDendrogram(maSteps, maObservations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dend := range []*Dendrogram{maDendrogram(), goDend} {
+		count := 0
+		for i, s := range dend.All() {
+			if i != count {
+				t.Fatalf("expected index %d, but got %d\n", count, i)
+			}
+			assertStepApproxEq(t, i, s, maSteps[i])
+			count++
+		}
+		if count != len(maSteps) {
+			t.Fatalf("expected %d steps, but got %d\n", len(maSteps), count)
+		}
+
+		count = 0
+		for i := range dend.All() {
+			if i == 2 {
+				break
+			}
+			count++
+		}
+		if count != 2 {
+			t.Fatalf("expected to stop after 2 steps, but got %d\n", count)
+		}
+	}
+
+	for range Linkage64([]float64{}, 1, MethodAverage).All() {
+		t.Fatalf("expected no steps for empty dendrogram\n")
+	}
+}
+
+func TestChildSizes(t *testing.T) {
+	dend := maDendrogram()
+	expected := [][2]int{{1, 1}, {1, 2}, {1, 3}, {1, 4}, {1, 5}}
+	for i, sizes := range expected {
+		size1, size2 := dend.ChildSizes(i)
+		if size1 != sizes[0] || size2 != sizes[1] {
+			t.Fatalf("step %d: expected sizes %v, but got [%d %d]\n",
+				i, sizes, size1, size2)
+		}
+	}
+
+	goDend, err := NewDendrogram(maSteps, maObservations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []*Dendrogram{dend, goDend} {
+		for _, i := range []int{-1, maObservations - 1} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Fatalf("expected panic for step index %d\n", i)
+					}
+				}()
+				d.ChildSizes(i)
+			}()
+		}
+	}
+}
+
+func TestStepForCluster(t *testing.T) {
+	dend := maDendrogram()
+	for label := 0; label < maObservations; label++ {
+		if i, leaf := dend.StepForCluster(label); i != -1 || !leaf {
+			t.Fatalf("label %d: expected leaf, but got step %d\n", label, i)
+		}
+	}
+	// Every child of a step that is not a leaf was created by an earlier
+	// step whose label is that child.
+	for i, s := range dend.Steps() {
+		for _, label := range []int{s.Cluster1, s.Cluster2} {
+			j, leaf := dend.StepForCluster(label)
+			if leaf {
+				continue
+			}
+			if j >= i || maObservations+j != label {
+				t.Fatalf("step %d: expected label %d to be created by an earlier step, but got %d\n",
+					i, label, j)
+			}
+		}
+	}
+	for _, label := range []int{-1, 2*maObservations - 1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expected panic for label %d\n", label)
+				}
+			}()
+			dend.StepForCluster(label)
+		}()
+	}
+}
+
+func TestValidateSteps(t *testing.T) {
+	if err := ValidateSteps(maSteps, maObservations); err != nil {
+		t.Fatal(err)
+	}
+	swapped := append([]Step{}, maSteps...)
+	swapped[3] = Step{8, 3, swapped[3].Dissimilarity, swapped[3].Size}
+	err := ValidateSteps(swapped, maObservations)
+	if err == nil || !strings.HasPrefix(err.Error(), "step 3:") {
+		t.Fatalf("expected error naming step 3, but got %v", err)
+	}
+	// NewDendrogram does not enforce the label convention.
+	if _, err := NewDendrogram(swapped, maObservations); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateSteps(maSteps[1:], maObservations); err == nil {
+		t.Fatal("expected error for too few steps")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	dend := maDendrogram()
+	goDend, err := NewDendrogram(maSteps, maObservations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dend.Equal(goDend, 0.000001) || !goDend.Equal(dend, 0.000001) {
+		t.Fatal("expected dendrograms to be equal")
+	}
+
+	shifted := append([]Step{}, maSteps...)
+	shifted[2].Dissimilarity += 0.01
+	other, err := NewDendrogram(shifted, maObservations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dend.Equal(other, 0.001) {
+		t.Fatal("expected dendrograms to differ beyond tolerance")
+	}
+	if !dend.Equal(other, 0.1) {
+		t.Fatal("expected dendrograms to be equal within tolerance")
+	}
+
+	swapped := append([]Step{}, maSteps...)
+	swapped[3].Cluster1, swapped[3].Cluster2 = swapped[3].Cluster2, swapped[3].Cluster1
+	other, err = NewDendrogram(swapped, maObservations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dend.Equal(other, 1) {
+		t.Fatal("expected dendrograms with different labels to differ")
+	}
+	if dend.Equal(Linkage64([]float64{}, 0, MethodAverage), 1) {
+		t.Fatal("expected dendrograms with different observations to differ")
+	}
+}
+
+func TestConcurrentReads(t *testing.T) {
+	goDend, err := NewDendrogram(maSteps, maObservations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dend := range []*Dendrogram{maDendrogram(), goDend} {
+		var wg sync.WaitGroup
+		errs := make(chan error, 16)
+		for g := 0; g < 16; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 100; i++ {
+					if n := dend.Len(); n != len(maSteps) {
+						errs <- fmt.Errorf("expected %d steps, but got %d", len(maSteps), n)
+						return
+					}
+					if n := dend.Observations(); n != maObservations {
+						errs <- fmt.Errorf("expected %d observations, but got %d", maObservations, n)
+						return
+					}
+					steps := dend.Steps()
+					for j := range steps {
+						if math.Abs(steps[j].Dissimilarity-maSteps[j].Dissimilarity) > 1e-9 {
+							errs <- fmt.Errorf("step %d: expected %v, but got %v", j, maSteps[j], steps[j])
+							return
+						}
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStepsWithoutReference(t *testing.T) {
+	// Each dendrogram is unreachable as soon as its steps start being read,
+	// so a collection during the read would free them if the dendrogram
+	// were not kept alive until the read finished.
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				runtime.GC()
+			}
+		}
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+	for i := 0; i < 200; i++ {
+		steps := Linkage64Copy(maCondensedMatrix64, maObservations, MethodAverage).Steps()
+		for j := range steps {
+			assertStepApproxEq(t, j, steps[j], maSteps[j])
+		}
+		root, _ := Linkage64Copy(maCondensedMatrix64, maObservations, MethodAverage).Root()
+		assertStepApproxEq(t, len(maSteps)-1, root, maSteps[len(maSteps)-1])
+	}
+}
+
+func TestClose(t *testing.T) {
+	goDend, err := NewDendrogram(maSteps, maObservations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dend := range []*Dendrogram{maDendrogram(), goDend} {
+		if err := dend.Close(); err != nil {
+			t.Fatal(err)
+		}
+		// Closing twice is safe.
+		if err := dend.Close(); err != nil {
+			t.Fatal(err)
+		}
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected panic after Close")
+				}
+			}()
+			dend.Steps()
+		}()
+	}
+}
+
+func TestUseAfterClose(t *testing.T) {
+	dend := maDendrogram()
+	dend.Close()
+
+	var nilDend *Dendrogram
+	if err := nilDend.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	uses := map[string]func(d *Dendrogram){
+		"Len":          func(d *Dendrogram) { d.Len() },
+		"Observations": func(d *Dendrogram) { d.Observations() },
+		"Steps":        func(d *Dendrogram) { d.Steps() },
+		"FlatClusters": func(d *Dendrogram) { d.FlatClusters(1) },
+		"Cophenetic":   func(d *Dendrogram) { d.Cophenetic() },
+		"Newick":       func(d *Dendrogram) { d.Newick(nil) },
+		"MarshalJSON":  func(d *Dendrogram) { d.MarshalJSON() },
+	}
+	for name, use := range uses {
+		for _, d := range []*Dendrogram{dend, nilDend} {
+			t.Run(name, func(t *testing.T) {
+				defer func() {
+					r := recover()
+					msg, ok := r.(string)
+					if !ok || !strings.HasPrefix(msg, "kodama: ") {
+						t.Fatalf("expected descriptive panic, but got %#v", r)
+					}
+				}()
+				use(d)
+			})
+		}
+	}
+}
+
+func BenchmarkLinkage64(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	for _, n := range []int{100, 1000, 5000} {
+		dis, err := EuclideanCondensed(randomPoints(rng, n, 3), n, 3)
+		if err != nil {
+			b.Fatal(err)
+		}
+		matrix := make([]float64, len(dis))
+		for _, method := range allMethods {
+			b.Run(fmt.Sprintf("n=%d/%v", n, method), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					// Clustering mutates the matrix, so start each
+					// iteration from a fresh copy.
+					b.StopTimer()
+					copy(matrix, dis)
+					b.StartTimer()
+					Linkage64(matrix, n, method).Close()
+				}
+			})
+		}
+	}
+}
+
+func TestLinkage64Until(t *testing.T) {
+	matrix := append([]float64{}, maCondensedMatrix64...)
+	var seen []Step
+	dend, err := Linkage64Until(matrix, maObservations, MethodAverage, func(s Step) bool {
+		seen = append(seen, s)
+		return s.Dissimilarity > 8
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dend.Observations() != maObservations || dend.Len() != 2 {
+		t.Fatalf("expected 2 steps over %d observations, but got %d over %d\n",
+			maObservations, dend.Len(), dend.Observations())
+	}
+	steps := dend.Steps()
+	for i := range steps {
+		assertStepApproxEq(t, i, steps[i], maSteps[i])
+	}
+	// The step that stopped clustering is seen, but nothing after it.
+	if len(seen) != 3 {
+		t.Fatalf("expected stop to be called 3 times, but got %d\n", len(seen))
+	}
+	// Only the cluster {2, 4, 5} was formed, and observations 0, 1 and 3
+	// were never merged.
+	expected := []int{0, 1, 2, 3, 2, 2}
+	if got := dend.FlatClusters(math.Inf(1)); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, but got %v\n", expected, got)
+	}
+}
+
+func TestLinkage64UntilSize(t *testing.T) {
+	matrix := append([]float64{}, maCondensedMatrix64...)
+	dend, err := Linkage64Until(matrix, maObservations, MethodAverage, func(s Step) bool {
+		return s.Size > 3
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dend.Len() != 2 {
+		t.Fatalf("expected 2 steps, but got %d\n", dend.Len())
+	}
+}
+
+func TestLinkage64UntilNeverOrAlways(t *testing.T) {
+	matrix := append([]float64{}, maCondensedMatrix64...)
+	never, err := Linkage64Until(matrix, maObservations, MethodAverage, func(Step) bool {
+		return false
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !never.Equal(maDendrogram(), 1e-12) {
+		t.Fatalf("expected %v, but got %v\n", maSteps, never.Steps())
+	}
+
+	matrix = append([]float64{}, maCondensedMatrix64...)
+	always, err := Linkage64Until(matrix, maObservations, MethodAverage, func(Step) bool {
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if always.Len() != 0 || always.Observations() != maObservations {
+		t.Fatalf("expected no steps, but got %v\n", always.Steps())
+	}
+}
+
+func TestLinkage64UntilInvalid(t *testing.T) {
+	_, err := Linkage64Until(maCondensedMatrix64[1:], maObservations, MethodAverage,
+		func(Step) bool { return false })
+	if err == nil {
+		t.Fatal("expected error for mismatched matrix length")
+	}
+}
+
+func TestRoots(t *testing.T) {
+	dend := maDendrogram()
+	if got := dend.Roots(); !reflect.DeepEqual(got, []int{10}) {
+		t.Fatalf("expected [10], but got %v\n", got)
+	}
+	if got := dend.NumTrees(); got != 1 {
+		t.Fatalf("expected 1 tree, but got %d\n", got)
+	}
+
+	matrix := append([]float64{}, maCondensedMatrix64...)
+	partial, err := Linkage64Until(matrix, maObservations, MethodAverage, func(s Step) bool {
+		return s.Dissimilarity > 8
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []int{0, 1, 3, 7}
+	if got := partial.Roots(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, but got %v\n", expected, got)
+	}
+	if got := partial.NumTrees(); got != len(expected) {
+		t.Fatalf("expected %d trees, but got %d\n", len(expected), got)
+	}
+}
+
+func TestRootsTrivial(t *testing.T) {
+	for obs, expected := range [][]int{{}, {0}} {
+		dend := Linkage64([]float64{}, obs, MethodAverage)
+		if got := dend.Roots(); !reflect.DeepEqual(got, expected) {
+			t.Fatalf("expected %v, but got %v\n", expected, got)
+		}
+		if got := dend.NumTrees(); got != len(expected) {
+			t.Fatalf("expected %d trees, but got %d\n", len(expected), got)
+		}
+	}
+}
+
+func TestLinkage64Similarity(t *testing.T) {
+	// Negated dissimilarities are similarities, and negating them again
+	// recovers the original dendrogram.
+	similarity := make([]float64, len(maCondensedMatrix64))
+	for i, d := range maCondensedMatrix64 {
+		similarity[i] = -d
+	}
+	dend, err := Linkage64Similarity(similarity, maObservations, MethodAverage,
+		func(sim float64) float64 { return -sim })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dend.Equal(maDendrogram(), 1e-12) {
+		t.Fatalf("expected %v, but got %v\n", maSteps, dend.Steps())
+	}
+	if similarity[0] != -maCondensedMatrix64[0] {
+		t.Fatal("expected similarity matrix to be unchanged")
+	}
+}
+
+func TestLinkage64SimilarityDefault(t *testing.T) {
+	// With the default conversion of 1 - sim, similarities scaled into
+	// [0, 1] produce the same merges at scaled dissimilarities.
+	const scale = 100.0
+	similarity := make([]float64, len(maCondensedMatrix64))
+	for i, d := range maCondensedMatrix64 {
+		similarity[i] = 1 - d/scale
+	}
+	dend, err := Linkage64Similarity(similarity, maObservations, MethodAverage, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := dend.Steps()
+	for i, expected := range maSteps {
+		expected.Dissimilarity /= scale
+		assertStepApproxEq(t, i, steps[i], expected)
+	}
+}
+
+func TestLinkage64SimilarityInvalid(t *testing.T) {
+	// Similarities greater than 1 convert to negative dissimilarities.
+	if _, err := Linkage64Similarity([]float64{0.5, 2, 0.5}, 3, MethodAverage, nil); err == nil {
+		t.Fatal("expected error for negative dissimilarity")
+	}
+	nan := func(float64) float64 { return math.NaN() }
+	if _, err := Linkage64Similarity([]float64{0.5, 0.5, 0.5}, 3, MethodAverage, nan); err == nil {
+		t.Fatal("expected error for NaN dissimilarity")
+	}
+	if _, err := Linkage64Similarity([]float64{0.5}, 3, MethodAverage, nil); err == nil {
+		t.Fatal("expected error for mismatched matrix length")
+	}
+}
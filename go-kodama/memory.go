@@ -0,0 +1,40 @@
+package kodama
+
+import "fmt"
+
+// workingBytesPerObservation is an upper bound on the memory used per
+// observation by clustering, aside from the condensed matrix itself. This
+// covers the bookkeeping arrays used by each algorithm (cluster sizes, the
+// set of active clusters, the nearest-neighbor chain or priority queue),
+// the steps of the dendrogram as built by the C library, the union-find
+// used to label them and their copy in Go memory.
+const workingBytesPerObservation = 160
+
+// EstimatedBytes returns the approximate peak memory, in bytes, used to
+// cluster the given number of observations with dissimilarities that are
+// the given number of bits wide, i.e., 32 for Linkage32 or 64 for
+// Linkage64.
+//
+// The estimate is dominated by the condensed dissimilarity matrix, which
+// has observations-choose-2 elements of bits/8 bytes each, plus a small
+// amount of working memory per observation. It does not include any other
+// copy of the matrix that the caller makes, e.g., with Linkage64Copy or
+// LinkageSquare64. For example, clustering 100,000 observations with
+// Linkage64 needs about 40 GB, which makes this useful for rejecting
+// oversized inputs up front instead of running out of memory.
+//
+// This panics if the number of observations is negative or if bits is not
+// 32 or 64.
+func EstimatedBytes(observations int, bits int) int64 {
+	if observations < 0 {
+		panic(fmt.Errorf(
+			"expected non-negative number of observations, but got %d",
+			observations))
+	}
+	if bits != 32 && bits != 64 {
+		panic(fmt.Errorf("expected 32 or 64 bits, but got %d", bits))
+	}
+	n := int64(observations)
+	matrix := (n * (n - 1)) / 2 * int64(bits/8)
+	return matrix + n*workingBytesPerObservation
+}
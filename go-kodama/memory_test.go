@@ -0,0 +1,44 @@
+package kodama
+
+import "testing"
+
+func TestEstimatedBytes(t *testing.T) {
+	if got := EstimatedBytes(0, 64); got != 0 {
+		t.Fatalf("expected 0 bytes, but got %d\n", got)
+	}
+	// The condensed matrix for 100,000 observations alone is about 40 GB.
+	got := EstimatedBytes(100000, 64)
+	if got < 39.9e9 || got > 40.1e9 {
+		t.Fatalf("expected about 40 GB, but got %d\n", got)
+	}
+	if half := EstimatedBytes(100000, 32); half < got/2 || half >= got {
+		t.Fatalf("expected 32 bits to need about half of %d bytes, but got %d\n",
+			got, half)
+	}
+	// The estimate must never be less than the matrix itself.
+	n := maObservations
+	if got := EstimatedBytes(n, 64); got < int64(8*len(maCondensedMatrix64)) {
+		t.Fatalf("expected at least %d bytes, but got %d\n",
+			8*len(maCondensedMatrix64), got)
+	}
+}
+
+func TestEstimatedBytesInvalid(t *testing.T) {
+	tests := []struct {
+		observations, bits int
+	}{
+		{-1, 64},
+		{10, 16},
+		{10, 0},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("(%d, %d): expected panic\n", test.observations, test.bits)
+				}
+			}()
+			EstimatedBytes(test.observations, test.bits)
+		}()
+	}
+}
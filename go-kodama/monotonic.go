@@ -0,0 +1,61 @@
+package kodama
+
+// IsMonotonic returns true if and only if no step in this dendrogram has a
+// smaller dissimilarity than either of the steps that created its children.
+//
+// Dendrograms produced by single, complete, average, weighted and Ward
+// linkage are always monotonic. Centroid and median linkage may produce
+// inversions, which are reported by Inversions.
+func (dend *Dendrogram) IsMonotonic() bool {
+	return len(dend.Inversions()) == 0
+}
+
+// Inversions returns the indices of the steps in this dendrogram whose
+// dissimilarity is smaller than the dissimilarity of either of the steps
+// that created its children, in ascending order.
+//
+// Inversions typically break visualizations and make cutting the tree at a
+// dissimilarity threshold ambiguous. This runs in time linear in the number
+// of steps.
+func (dend *Dendrogram) Inversions() []int {
+	obs := dend.Observations()
+	steps := dend.Steps()
+	inversions := []int{}
+	for i, s := range steps {
+		for _, c := range [2]int{s.Cluster1, s.Cluster2} {
+			if c >= obs && s.Dissimilarity < steps[c-obs].Dissimilarity {
+				inversions = append(inversions, i)
+				break
+			}
+		}
+	}
+	return inversions
+}
+
+// MakeUltrametric returns a copy of this dendrogram with every inversion
+// removed, so that the result is monotonic and its cophenetic distances are
+// ultrametric.
+//
+// This is the cumulative maximum correction: each step's dissimilarity is
+// raised to the largest dissimilarity of any step beneath it, which leaves
+// the structure of the tree unchanged. Note that this changes the
+// dissimilarities of the inverted steps, so they no longer reflect the
+// dissimilarities computed by the linkage method. A dendrogram that is
+// already monotonic is copied unchanged.
+//
+// The returned dendrogram is backed entirely by Go memory and is
+// independent of this one.
+func (dend *Dendrogram) MakeUltrametric() *Dendrogram {
+	obs := dend.Observations()
+	steps := dend.Steps()
+	for i, s := range steps {
+		for _, c := range [2]int{s.Cluster1, s.Cluster2} {
+			if c >= obs && steps[c-obs].Dissimilarity > steps[i].Dissimilarity {
+				steps[i].Dissimilarity = steps[c-obs].Dissimilarity
+			}
+		}
+	}
+	corrected := &Dendrogram{}
+	corrected.setSteps(steps, obs)
+	return corrected
+}
@@ -0,0 +1,65 @@
+package kodama
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestInversions(t *testing.T) {
+	if dend := maDendrogram(); !dend.IsMonotonic() {
+		t.Fatalf("expected monotonic dendrogram, but got inversions %v\n", dend.Inversions())
+	}
+
+	// The vertices of an equilateral triangle. After merging two of them,
+	// their centroid is closer to the third than they were to each other.
+	triangle := []float64{
+		0, 0,
+		1, 0,
+		0.5, math.Sqrt(3) / 2,
+	}
+	dis, err := CondensedMatrix(triangle, 3, 2, MetricEuclidean)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dend := Linkage64(dis, 3, MethodCentroid)
+	if dend.IsMonotonic() {
+		t.Fatalf("expected inversion, but got steps %v\n", dend.Steps())
+	}
+	if got := dend.Inversions(); !reflect.DeepEqual(got, []int{1}) {
+		t.Fatalf("expected inversions [1], but got %v\n", got)
+	}
+}
+
+func TestMakeUltrametric(t *testing.T) {
+	dend := maDendrogram()
+	if got := dend.MakeUltrametric(); !got.Equal(dend, 0) {
+		t.Fatalf("expected monotonic dendrogram to be unchanged, but got %v\n", got.Steps())
+	}
+
+	// A centroid-like tree with an inversion at step 1 that is also
+	// inherited by step 2, whose dissimilarity is between the two.
+	inverted, err := NewDendrogram([]Step{
+		{0, 1, 3, 2},
+		{2, 4, 1, 3},
+		{3, 5, 2, 4},
+	}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := inverted.MakeUltrametric()
+	if !got.IsMonotonic() {
+		t.Fatalf("expected monotonic dendrogram, but got inversions %v\n", got.Inversions())
+	}
+	expected := []Step{
+		{0, 1, 3, 2},
+		{2, 4, 3, 3},
+		{3, 5, 3, 4},
+	}
+	if !reflect.DeepEqual(got.Steps(), expected) {
+		t.Fatalf("expected %v, but got %v\n", expected, got.Steps())
+	}
+	if !reflect.DeepEqual(inverted.Steps()[1], Step{2, 4, 1, 3}) {
+		t.Fatalf("expected original dendrogram to be unchanged, but got %v\n", inverted.Steps())
+	}
+}
@@ -0,0 +1,110 @@
+package kodama
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Newick returns this dendrogram as a tree in the Newick format, which is
+// understood by most tree visualization tools.
+//
+// Leaf names are taken from labels, which is indexed by observation. If
+// labels is empty, then each leaf is named by its observation index. If
+// labels is not empty and its length is not equal to Observations(), then
+// an error is returned. Names that contain whitespace or any of the
+// characters reserved by Newick are quoted.
+//
+// Clusters are nested according to the steps of this dendrogram. The length
+// of the branch above each cluster is the dissimilarity of its parent's
+// merge minus the dissimilarity of its own merge, where leaves are treated
+// as having a dissimilarity of zero. The returned string is always
+// terminated by a semicolon. A partial dendrogram, e.g., as returned by
+// Linkage64Until, is written as one tree per root in the order of Roots,
+// each terminated by a semicolon and separated by newlines, as in a Newick
+// file containing multiple trees.
+func (dend *Dendrogram) Newick(labels []string) (string, error) {
+	obs := dend.Observations()
+	if len(labels) > 0 && len(labels) != obs {
+		return "", fmt.Errorf(
+			"expected %d labels, but got %d", obs, len(labels))
+	}
+	if obs == 0 {
+		return ";", nil
+	}
+
+	steps := dend.Steps()
+	height := func(label int) float64 {
+		if label < obs {
+			return 0
+		}
+		return steps[label-obs].Dissimilarity
+	}
+	var buf strings.Builder
+	var write func(label int)
+	write = func(label int) {
+		if label < obs {
+			if len(labels) == 0 {
+				buf.WriteString(strconv.Itoa(label))
+			} else {
+				buf.WriteString(newickName(labels[label]))
+			}
+			return
+		}
+		s := steps[label-obs]
+		buf.WriteByte('(')
+		for i, child := range [2]int{s.Cluster1, s.Cluster2} {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			write(child)
+			buf.WriteByte(':')
+			buf.WriteString(strconv.FormatFloat(
+				s.Dissimilarity-height(child), 'g', -1, 64))
+		}
+		buf.WriteByte(')')
+	}
+	for i, root := range dend.Roots() {
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		write(root)
+		buf.WriteByte(';')
+	}
+	return buf.String(), nil
+}
+
+// BranchLengths returns the length of the branch above the cluster created
+// by each step, i.e., the dissimilarity of the step that merges that
+// cluster into its parent minus the dissimilarity of the step itself. These
+// are the branch lengths written by Newick for every cluster that is not a
+// leaf. (A leaf's branch length is simply the dissimilarity of the step
+// that merges it.)
+//
+// The returned slice has length Len(), where the ith element is the branch
+// length above the ith step. The root, created by the last step, has no
+// parent, so its branch length is 0. Branch lengths are never negative
+// unless this dendrogram has inversions (see IsMonotonic), as may happen
+// with centroid or median linkage.
+func (dend *Dendrogram) BranchLengths() []float64 {
+	obs := dend.Observations()
+	steps := dend.Steps()
+	lengths := make([]float64, len(steps))
+	for _, s := range steps {
+		for _, child := range [2]int{s.Cluster1, s.Cluster2} {
+			if child >= obs {
+				lengths[child-obs] = s.Dissimilarity - steps[child-obs].Dissimilarity
+			}
+		}
+	}
+	return lengths
+}
+
+// newickName quotes the given leaf name if it contains whitespace or any
+// characters that have special meaning in the Newick format.
+func newickName(name string) string {
+	if name != "" && !strings.ContainsAny(name, " \t\r\n()[]':;,") {
+		return name
+	}
+	return "'" + strings.ReplaceAll(name, "'", "''") + "'"
+}
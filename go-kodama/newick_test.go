@@ -0,0 +1,84 @@
+package kodama
+
+import "testing"
+
+func TestNewick(t *testing.T) {
+	dend := Linkage64([]float64{1, 4, 3}, 3, MethodSingle)
+
+	got, err := dend.Newick(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "(2:3,(0:1,1:1):2);"; got != expected {
+		t.Fatalf("expected %q, but got %q\n", expected, got)
+	}
+
+	got, err = dend.Newick([]string{"a", "b c", "it's"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "('it''s':3,(a:1,'b c':1):2);"; got != expected {
+		t.Fatalf("expected %q, but got %q\n", expected, got)
+	}
+}
+
+func TestNewickTrivial(t *testing.T) {
+	for obs, expected := range []string{";", "0;"} {
+		dend := Linkage64([]float64{}, obs, MethodSingle)
+		got, err := dend.Newick(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != expected {
+			t.Fatalf("expected %q, but got %q\n", expected, got)
+		}
+	}
+}
+
+func TestNewickBadLabels(t *testing.T) {
+	if _, err := maDendrogram().Newick([]string{"a"}); err == nil {
+		t.Fatal("expected error for mismatched number of labels")
+	}
+}
+
+func TestBranchLengths(t *testing.T) {
+	dend, err := NewDendrogram(maSteps, maObservations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The steps of maSteps form a chain, so each step's parent is the next
+	// step.
+	expected := make([]float64, len(maSteps))
+	for i := 0; i < len(maSteps)-1; i++ {
+		expected[i] = maSteps[i+1].Dissimilarity - maSteps[i].Dissimilarity
+	}
+	assertFloatsApproxEq(t, dend.BranchLengths(), expected)
+
+	// A balanced tree: (0, 1) at 1, (2, 3) at 2, and both at 5.
+	balanced, err := NewDendrogram([]Step{
+		{0, 1, 1, 2},
+		{2, 3, 2, 2},
+		{4, 5, 5, 4},
+	}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFloatsApproxEq(t, balanced.BranchLengths(), []float64{4, 3, 0})
+
+	empty := Linkage64([]float64{}, 1, MethodAverage)
+	if got := empty.BranchLengths(); len(got) != 0 {
+		t.Fatalf("expected no branch lengths, but got %v\n", got)
+	}
+}
+
+func TestNewickForest(t *testing.T) {
+	got, err := maPartialDendrogram().Newick(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "0;\n1;\n3;\n" +
+		"(5:5.757158112027513,(2:3.1237967760688776,4:3.1237967760688776):2.6333613359586354);"
+	if got != expected {
+		t.Fatalf("expected %q, but got %q\n", expected, got)
+	}
+}
@@ -0,0 +1,100 @@
+package kodama
+
+import (
+	"fmt"
+	"math"
+)
+
+// AddObservation returns a new dendrogram that also clusters one more
+// observation, given its dissimilarities to every existing observation.
+// This dendrogram is not modified.
+//
+// The ith element of distances is the dissimilarity between the new
+// observation and observation i, so there must be exactly Observations()
+// of them, and each must be finite and non-NaN. The new observation is
+// given the next observation label, which is the old number of
+// observations, and so every label of a cluster created by a step is one
+// greater in the returned dendrogram.
+//
+// This avoids reclustering from scratch, but it is approximate. First, the
+// nearest existing observation to the new one is found (the one with the
+// smallest label in the case of ties), at dissimilarity d. The new
+// observation is then merged, at dissimilarity d, with the largest cluster
+// containing that neighbor that was formed below d. No other merges
+// change. If this dendrogram is monotonic (see IsMonotonic), then the new
+// merge is ordered among the other steps by its dissimilarity, as in any
+// dendrogram produced by clustering. Otherwise, it immediately precedes the
+// step that merges that cluster further. In particular, the new observation never brings two existing
+// clusters closer together, which it would under full reclustering if it
+// lies between them. For single linkage, the result is exactly the
+// dendrogram that full reclustering would produce whenever this does not
+// happen. For every other method, the dissimilarities of the merges above
+// the new observation are not updated to account for it, so the
+// dendrogram drifts from the one that full reclustering would produce as
+// more observations are added. Callers should periodically recluster all
+// of their observations to correct for this.
+func (dend *Dendrogram) AddObservation(distances []float64) (*Dendrogram, error) {
+	n := dend.Observations()
+	if len(distances) != n {
+		return nil, fmt.Errorf(
+			"expected %d distances, but got %d", n, len(distances))
+	}
+	nearest := -1
+	for i, d := range distances {
+		if math.IsNaN(d) || math.IsInf(d, 0) {
+			return nil, fmt.Errorf(
+				"distance to observation %d is %v", i, d)
+		}
+		if nearest < 0 || d < distances[nearest] {
+			nearest = i
+		}
+	}
+	if n == 0 {
+		return NewDendrogram(nil, 1)
+	}
+
+	// Identify each cluster by its smallest observation, which is the form
+	// expected by relabelSteps, and find the parent step of each cluster.
+	steps := dend.Steps()
+	leastObs := make([]int, n+len(steps))
+	parent := make([]int, n+len(steps))
+	for i := 0; i < n; i++ {
+		leastObs[i] = i
+	}
+	for i := range parent {
+		parent[i] = -1
+	}
+	merges := make([]Step, 0, len(steps)+1)
+	for i, s := range steps {
+		leastObs[n+i] = min(leastObs[s.Cluster1], leastObs[s.Cluster2])
+		parent[s.Cluster1], parent[s.Cluster2] = i, i
+		merges = append(merges, Step{
+			Cluster1:      leastObs[s.Cluster1],
+			Cluster2:      leastObs[s.Cluster2],
+			Dissimilarity: s.Dissimilarity,
+		})
+	}
+
+	// Climb from the nearest neighbor to the largest cluster formed below
+	// d, and insert the new merge just before the step that merges that
+	// cluster into its parent (or at the end, if it is the root). Every
+	// step beneath the new merge is below d and its parent is not, so for
+	// a monotonic dendrogram, a stable sort by dissimilarity keeps the
+	// merges consistent while also putting the new merge among any
+	// unrelated steps in order.
+	d := distances[nearest]
+	cluster := nearest
+	for parent[cluster] >= 0 && steps[parent[cluster]].Dissimilarity < d {
+		cluster = n + parent[cluster]
+	}
+	at := len(merges)
+	if parent[cluster] >= 0 {
+		at = parent[cluster]
+	}
+	merges = append(merges, Step{})
+	copy(merges[at+1:], merges[at:])
+	merges[at] = Step{Cluster1: leastObs[cluster], Cluster2: n, Dissimilarity: d}
+
+	relabelSteps(merges, n+1, dend.IsMonotonic())
+	return NewDendrogram(merges, n+1)
+}
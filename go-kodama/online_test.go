@@ -0,0 +1,141 @@
+package kodama
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// maDistancesTo returns the dissimilarities between the given observation
+// and every observation before it in the MA data set.
+func maDistancesTo(obs int) []float64 {
+	distances := make([]float64, obs)
+	for i := range distances {
+		distances[i] = maCondensedMatrix64[condensedIndex(maObservations, i, obs)]
+	}
+	return distances
+}
+
+func TestAddObservation(t *testing.T) {
+	// Adding a point to the end of a line of points never brings two
+	// existing clusters closer together, so for single linkage, the result
+	// matches clustering every point from scratch.
+	points := []float64{0, 1, 3, 7, 15}
+	dis := func(n int) []float64 {
+		var matrix []float64
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				matrix = append(matrix, math.Abs(points[i]-points[j]))
+			}
+		}
+		return matrix
+	}
+	n := len(points) - 1
+	dend := Linkage64(dis(n), n, MethodSingle)
+	distances := make([]float64, n)
+	for i := range distances {
+		distances[i] = math.Abs(points[i] - points[n])
+	}
+	got, err := dend.AddObservation(distances)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Linkage64(dis(n+1), n+1, MethodSingle)
+	if !got.Equal(expected, 0) {
+		t.Fatalf("expected %v, but got %v\n", expected.Steps(), got.Steps())
+	}
+	if dend.Observations() != n {
+		t.Fatalf("expected receiver to be unchanged, but got %d observations\n",
+			dend.Observations())
+	}
+}
+
+func TestAddObservationOrder(t *testing.T) {
+	// The pairs {0, 1} and {2, 3} are merged at 1 and 5, and then joined at
+	// 99. The new point is nearest to observation 0 at 2, so its merge
+	// belongs between the two pairs, not just before the root.
+	points := []float64{0, 1, 100, 105, -2}
+	var matrix []float64
+	for i := range points {
+		for j := i + 1; j < len(points); j++ {
+			matrix = append(matrix, math.Abs(points[i]-points[j]))
+		}
+	}
+	n := len(points) - 1
+	old := make([]float64, 0, (n*(n-1))/2)
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			old = append(old, matrix[condensedIndex(n+1, i, j)])
+		}
+	}
+	dend := Linkage64(old, n, MethodSingle)
+	distances := make([]float64, n)
+	for i := range distances {
+		distances[i] = math.Abs(points[i] - points[n])
+	}
+	got, err := dend.AddObservation(distances)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Linkage64Copy(matrix, n+1, MethodSingle)
+	if !reflect.DeepEqual(got.Steps(), expected.Steps()) {
+		t.Fatalf("expected %v, but got %v\n", expected.Steps(), got.Steps())
+	}
+	if k := got.NumClustersAtHeight(2.5); k != 3 {
+		t.Fatalf("expected 3 clusters at 2.5, but got %d\n", k)
+	}
+}
+
+func TestAddObservationRepeated(t *testing.T) {
+	// Adding observations one at a time to an empty dendrogram always
+	// produces a valid dendrogram over all of them.
+	dend, err := NewDendrogram(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for obs := 0; obs < maObservations; obs++ {
+		dend, err = dend.AddObservation(maDistancesTo(obs))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ValidateSteps(dend.Steps(), obs+1); err != nil {
+			t.Fatalf("after adding observation %d: %v\n", obs, err)
+		}
+	}
+	root, _ := dend.Root()
+	if root.Size != maObservations {
+		t.Fatalf("expected root of size %d, but got %v\n", maObservations, root)
+	}
+}
+
+func TestAddObservationBetween(t *testing.T) {
+	// Observations 0 and 1 are merged at 4, and the new observation is
+	// nearest to observation 1 at 1, so it joins observation 1 alone.
+	dend := Linkage64([]float64{4}, 2, MethodSingle)
+	got, err := dend.AddObservation([]float64{2, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Step{
+		{Cluster1: 1, Cluster2: 2, Dissimilarity: 1, Size: 2},
+		{Cluster1: 0, Cluster2: 3, Dissimilarity: 4, Size: 3},
+	}
+	steps := got.Steps()
+	for i := range expected {
+		if steps[i] != expected[i] {
+			t.Fatalf("step %d: expected %v, but got %v\n", i, expected[i], steps[i])
+		}
+	}
+}
+
+func TestAddObservationInvalid(t *testing.T) {
+	dend := maDendrogram()
+	if _, err := dend.AddObservation(maDistancesTo(3)); err == nil {
+		t.Fatal("expected error for wrong number of distances")
+	}
+	distances := make([]float64, maObservations)
+	distances[2] = math.NaN()
+	if _, err := dend.AddObservation(distances); err == nil {
+		t.Fatal("expected error for NaN distance")
+	}
+}
@@ -0,0 +1,68 @@
+package kodama
+
+import "fmt"
+
+// DefaultBeta is the conventional value of LinkageParams.Beta for
+// MethodFlexible, as recommended by Lance and Williams.
+const DefaultBeta = -0.25
+
+// LinkageParams describes how to cluster with LinkageWithParams64.
+//
+// It carries the linkage method along with any parameters that the method
+// requires beyond its name. Methods that take no parameters, which include
+// every method supported by Linkage64, only need Method to be set.
+type LinkageParams struct {
+	// Method is the linkage method to use.
+	Method Method
+	// Beta is the parameter of MethodFlexible, and is ignored by every
+	// other method. It must be in the range [-1, 1). Since 0 is a
+	// meaningful value (it makes flexible linkage equivalent to
+	// MethodWeighted), there is no implicit default, and callers should
+	// usually set it to DefaultBeta.
+	Beta float64
+}
+
+// LinkageWithParams64 returns a hierarchical clustering of observations
+// given their pairwise dissimilarities and a parameterized linkage method.
+//
+// For methods that take no parameters, this is equivalent to Linkage64E.
+// In particular, the given matrix is used as scratch space during
+// clustering and is mutated, and an error is returned for all of the same
+// reasons as Linkage64E.
+//
+// MethodFlexible computes flexible-beta linkage, which is popular in
+// ecology. When clusters A and B are merged, the dissimilarity between the
+// new cluster and every other cluster X is
+//
+//	(1 - Beta)/2 * d(A, X) + (1 - Beta)/2 * d(B, X) + Beta * d(A, B)
+//
+// Negative values of Beta space clusters further apart as they grow, which
+// counteracts the chaining of single linkage. Since the C library does not
+// support this method, it is computed by the pure Go implementation of
+// AlgorithmGeneric, and the matrix is mutated just the same. An error is
+// also returned if Beta is not in the range [-1, 1).
+func LinkageWithParams64(
+	condensedDissimilarityMatrix []float64,
+	observations int,
+	params LinkageParams,
+) (*Dendrogram, error) {
+	if params.Method != MethodFlexible {
+		return Linkage64E(condensedDissimilarityMatrix, observations, params.Method)
+	}
+	if !(params.Beta >= -1 && params.Beta < 1) {
+		return nil, fmt.Errorf(
+			"expected beta in range [-1, 1), but got %v", params.Beta)
+	}
+	if err := checkMatrixLen(len(condensedDissimilarityMatrix), observations); err != nil {
+		return nil, err
+	}
+	if err := checkFinite(condensedDissimilarityMatrix, observations); err != nil {
+		return nil, err
+	}
+	steps := goLinkageParams(
+		condensedDissimilarityMatrix, observations, MethodFlexible, AlgorithmGeneric,
+		goParams{beta: params.Beta})
+	dend := &Dendrogram{}
+	dend.setSteps(steps, observations)
+	return dend, nil
+}
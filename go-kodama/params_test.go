@@ -0,0 +1,85 @@
+package kodama
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLinkageWithParams64(t *testing.T) {
+	for _, method := range allMethods {
+		expected := Linkage64Copy(maCondensedMatrix64, maObservations, method)
+		dis := append([]float64{}, maCondensedMatrix64...)
+		got, err := LinkageWithParams64(dis, maObservations, LinkageParams{Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(expected, 0) {
+			t.Fatalf("%v: expected %v, but got %v\n", method, expected.Steps(), got.Steps())
+		}
+	}
+
+	params := LinkageParams{Method: Method(-1)}
+	if _, err := LinkageWithParams64([]float64{1}, 2, params); err == nil {
+		t.Fatal("expected error for invalid method")
+	}
+	params = LinkageParams{Method: MethodAverage}
+	if _, err := LinkageWithParams64([]float64{1}, 3, params); err == nil {
+		t.Fatal("expected error for mismatched matrix length")
+	}
+}
+
+func TestLinkageFlexible(t *testing.T) {
+	// The points 0, 1 and 3 on a line. After merging 0 and 1, the
+	// dissimilarity to 2 is 0.625*3 + 0.625*2 - 0.25*1.
+	params := LinkageParams{Method: MethodFlexible, Beta: DefaultBeta}
+	dend, err := LinkageWithParams64([]float64{1, 3, 2}, 3, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Step{{0, 1, 1, 2}, {2, 3, 2.875, 3}}
+	for i, s := range dend.Steps() {
+		assertStepApproxEq(t, i, s, expected[i])
+	}
+
+	// With a beta of 0, the update formula is the same as weighted
+	// linkage.
+	params.Beta = 0
+	dis := append([]float64{}, maCondensedMatrix64...)
+	got, err := LinkageWithParams64(dis, maObservations, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	weighted := Linkage64Copy(maCondensedMatrix64, maObservations, MethodWeighted)
+	if !got.Equal(weighted, 1e-9) {
+		t.Fatalf("expected %v, but got %v\n", weighted.Steps(), got.Steps())
+	}
+
+	for _, beta := range []float64{1, -1.5, math.NaN()} {
+		params.Beta = beta
+		if _, err := LinkageWithParams64([]float64{1}, 2, params); err == nil {
+			t.Fatalf("expected error for beta %v\n", beta)
+		}
+	}
+	params.Beta = DefaultBeta
+	if _, err := LinkageWithParams64([]float64{math.NaN()}, 2, params); err == nil {
+		t.Fatal("expected error for NaN dissimilarity")
+	}
+	if _, err := LinkageWithParams64([]float64{1}, 3, params); err == nil {
+		t.Fatal("expected error for mismatched matrix length")
+	}
+}
+
+func TestFlexibleRequiresParams(t *testing.T) {
+	if _, err := Linkage64E([]float64{1}, 2, MethodFlexible); err == nil {
+		t.Fatal("expected error for flexible linkage without parameters")
+	}
+	if _, err := LinkageWeighted64([]float64{1}, 2, []int{1, 1}, MethodFlexible); err == nil {
+		t.Fatal("expected error for flexible linkage without parameters")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic for flexible linkage without parameters")
+		}
+	}()
+	Linkage64([]float64{1}, 2, MethodFlexible)
+}
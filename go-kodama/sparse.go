@@ -0,0 +1,160 @@
+package kodama
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// SparsePair is the dissimilarity D between observations I and J.
+type SparsePair struct {
+	I, J int
+	D    float64
+}
+
+// LinkageSparse64 returns a hierarchical clustering of observations given
+// only some of their pairwise dissimilarities.
+//
+// Each pair of observations may be given at most once, in either order.
+// Every pair that is not given has a dissimilarity of missing, which is
+// typically math.Inf(1) to indicate that the observations are unrelated.
+// When missing is infinite, clusters that are only connected through missing
+// pairs are merged at a dissimilarity of +Inf, after every finite merge.
+//
+// For single linkage with a missing value of +Inf, the clustering is computed
+// directly from the given pairs as a minimum spanning forest, using memory
+// proportional to the number of pairs rather than the number of
+// observations squared. Steps with equal dissimilarities are ordered by the
+// order of their pairs in the input. For every other combination of method
+// and missing value, the pairs are expanded into a dense condensed matrix,
+// which is then clustered as if by Linkage64.
+//
+// An error is returned if the method is not valid, if the number of
+// observations is negative, if any pair refers to an observation out of
+// range, is a pair of an observation with itself or is given more than once,
+// or if any dissimilarity is NaN or negative infinity. The update formulas
+// of Ward, centroid and median linkage subtract dissimilarities, which is
+// undefined once two clusters are merged at an infinite dissimilarity, so an
+// error is also returned if they are used with an infinite missing value or
+// any infinite dissimilarity.
+func LinkageSparse64(
+	pairs []SparsePair,
+	observations int,
+	method Method,
+	missing float64,
+) (*Dendrogram, error) {
+	if err := checkMethod(method); err != nil {
+		return nil, err
+	}
+	if observations < 0 {
+		return nil, fmt.Errorf(
+			"expected non-negative number of observations, but got %d",
+			observations)
+	}
+	if err := checkSparseValue(missing, method); err != nil {
+		return nil, fmt.Errorf("missing dissimilarity: %v", err)
+	}
+	seen := make(map[[2]int]bool, len(pairs))
+	for k, p := range pairs {
+		if p.I < 0 || p.I >= observations || p.J < 0 || p.J >= observations {
+			return nil, fmt.Errorf(
+				"pair %d: expected observations in range [0, %d), but got (%d, %d)",
+				k, observations, p.I, p.J)
+		}
+		if p.I == p.J {
+			return nil, fmt.Errorf(
+				"pair %d: expected distinct observations, but got (%d, %d)",
+				k, p.I, p.J)
+		}
+		if err := checkSparseValue(p.D, method); err != nil {
+			return nil, fmt.Errorf("pair %d: %v", k, err)
+		}
+		key := [2]int{min(p.I, p.J), max(p.I, p.J)}
+		if seen[key] {
+			return nil, fmt.Errorf(
+				"pair %d: observations (%d, %d) were already given",
+				k, p.I, p.J)
+		}
+		seen[key] = true
+	}
+
+	if method == MethodSingle && math.IsInf(missing, 1) {
+		dend := &Dendrogram{}
+		dend.setSteps(sparseSingle(pairs, observations), observations)
+		return dend, nil
+	}
+	matrix := make([]float64, (observations*(observations-1))/2)
+	for i := range matrix {
+		matrix[i] = missing
+	}
+	for _, p := range pairs {
+		matrix[CondensedIndex(observations, p.I, p.J)] = p.D
+	}
+	return linkage64(matrix, observations, method), nil
+}
+
+// checkSparseValue returns an error if the given dissimilarity cannot be
+// clustered with the given method.
+func checkSparseValue(d float64, method Method) error {
+	if math.IsNaN(d) || math.IsInf(d, -1) {
+		return fmt.Errorf("expected a number or +Inf, but got %v", d)
+	}
+	if math.IsInf(d, 1) &&
+		(method == MethodWard || method == MethodCentroid || method == MethodMedian) {
+		return fmt.Errorf(
+			"%v linkage does not support infinite dissimilarities", method)
+	}
+	return nil
+}
+
+// sparseSingle computes single linkage from the given pairs using Kruskal's
+// algorithm, where every pair not given has an infinite dissimilarity.
+func sparseSingle(pairs []SparsePair, observations int) []Step {
+	sorted := append([]SparsePair{}, pairs...)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return sorted[a].D < sorted[b].D
+	})
+
+	set := newUnionFind(observations)
+	// labels and sizes map the root of each cluster in set to its current
+	// cluster label and number of observations.
+	labels := make([]int, observations)
+	sizes := make([]int, observations)
+	for i := range labels {
+		labels[i] = i
+		sizes[i] = 1
+	}
+	steps := make([]Step, 0, max(observations-1, 0))
+	merge := func(a, b int, d float64) {
+		label1, label2 := labels[a], labels[b]
+		size := sizes[a] + sizes[b]
+		set.union(a, b)
+		root := set.find(b)
+		labels[root] = observations + len(steps)
+		sizes[root] = size
+		steps = append(steps, Step{
+			Cluster1:      min(label1, label2),
+			Cluster2:      max(label1, label2),
+			Dissimilarity: d,
+			Size:          size,
+		})
+	}
+	for _, p := range sorted {
+		a, b := set.find(p.I), set.find(p.J)
+		if a != b {
+			merge(a, b, p.D)
+		}
+	}
+	// Whatever remains disconnected is joined at an infinite dissimilarity,
+	// in order of each component's smallest observation.
+	first := -1
+	for i := 0; i < observations; i++ {
+		root := set.find(i)
+		if first == -1 {
+			first = root
+		} else if root != set.find(first) {
+			merge(set.find(first), root, math.Inf(1))
+		}
+	}
+	return steps
+}
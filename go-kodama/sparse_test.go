@@ -0,0 +1,131 @@
+package kodama
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// randomSparse returns a random subset of the pairs of the given number of
+// observations with distinct dissimilarities, along with the equivalent
+// dense condensed matrix where every other pair is missing.
+func randomSparse(rng *rand.Rand, observations int, missing float64) ([]SparsePair, []float64) {
+	var pairs []SparsePair
+	dense := make([]float64, (observations*(observations-1))/2)
+	for i := 0; i < observations; i++ {
+		for j := i + 1; j < observations; j++ {
+			k := condensedIndex(observations, i, j)
+			dense[k] = missing
+			if rng.Intn(4) == 0 {
+				d := rng.Float64()
+				dense[k] = d
+				// Exercise both orders of the pair.
+				if rng.Intn(2) == 0 {
+					pairs = append(pairs, SparsePair{j, i, d})
+				} else {
+					pairs = append(pairs, SparsePair{i, j, d})
+				}
+			}
+		}
+	}
+	rng.Shuffle(len(pairs), func(a, b int) {
+		pairs[a], pairs[b] = pairs[b], pairs[a]
+	})
+	return pairs, dense
+}
+
+func TestLinkageSparse64Single(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for trial := 0; trial < 50; trial++ {
+		obs := 1 + rng.Intn(12)
+		pairs, dense := randomSparse(rng, obs, math.Inf(1))
+		got, err := LinkageSparse64(pairs, obs, MethodSingle, math.Inf(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ValidateSteps(got.Steps(), obs); err != nil {
+			t.Fatalf("trial %d: %s\n", trial, err)
+		}
+		expected := Linkage64(dense, obs, MethodSingle)
+		if !got.Equal(expected, 0) {
+			t.Fatalf("trial %d: expected %v, but got %v\n",
+				trial, expected.Steps(), got.Steps())
+		}
+	}
+}
+
+func TestLinkageSparse64Dense(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	methods := []Method{MethodSingle, MethodComplete, MethodAverage, MethodWard, MethodCentroid}
+	for _, method := range methods {
+		pairs, dense := randomSparse(rng, 10, 5)
+		got, err := LinkageSparse64(pairs, 10, method, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := Linkage64(dense, 10, method)
+		if !got.Equal(expected, 0) {
+			t.Fatalf("method %d: expected %v, but got %v\n",
+				method, expected.Steps(), got.Steps())
+		}
+	}
+
+	// Infinite missing values are supported by the dense path too.
+	pairs := []SparsePair{{0, 1, 1}, {3, 2, 2}}
+	dend, err := LinkageSparse64(pairs, 4, MethodAverage, math.Inf(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root, _ := dend.Root(); !math.IsInf(root.Dissimilarity, 1) {
+		t.Fatalf("expected root at +Inf, but got %v\n", root)
+	}
+}
+
+func TestLinkageSparse64Invalid(t *testing.T) {
+	inf := math.Inf(1)
+	tests := []struct {
+		pairs   []SparsePair
+		obs     int
+		method  Method
+		missing float64
+	}{
+		{nil, -1, MethodSingle, inf},
+		{[]SparsePair{{0, 3, 1}}, 3, MethodSingle, inf},
+		{[]SparsePair{{-1, 0, 1}}, 3, MethodSingle, inf},
+		{[]SparsePair{{1, 1, 1}}, 3, MethodSingle, inf},
+		{[]SparsePair{{0, 1, 1}, {1, 0, 2}}, 3, MethodSingle, inf},
+		{[]SparsePair{{0, 1, math.NaN()}}, 3, MethodSingle, inf},
+		{[]SparsePair{{0, 1, math.Inf(-1)}}, 3, MethodAverage, inf},
+		{nil, 3, MethodAverage, math.NaN()},
+		{nil, 3, MethodCentroid, inf},
+		{[]SparsePair{{0, 1, inf}}, 3, MethodMedian, 1},
+		{[]SparsePair{{0, 1, inf}}, 3, MethodWard, 1},
+	}
+	for i, test := range tests {
+		_, err := LinkageSparse64(test.pairs, test.obs, test.method, test.missing)
+		if err == nil {
+			t.Fatalf("test %d: expected error\n", i)
+		}
+	}
+}
+
+func TestLinkageSparse64WardInf(t *testing.T) {
+	// Three components would have Ward linkage merge two of them at +Inf
+	// and then compute Inf-Inf when updating the dissimilarity to the third.
+	pairs := []SparsePair{{0, 1, 1}, {2, 3, 2}, {4, 5, 3}}
+	_, err := LinkageSparse64(pairs, 6, MethodWard, math.Inf(1))
+	if err == nil {
+		t.Fatalf("expected error for Ward linkage with infinite missing value\n")
+	}
+
+	// A finite missing value keeps every update finite.
+	dend, err := LinkageSparse64(pairs, 6, MethodWard, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, step := range dend.Steps() {
+		if math.IsNaN(step.Dissimilarity) || math.IsInf(step.Dissimilarity, 0) {
+			t.Fatalf("step %d: expected finite dissimilarity, but got %v\n", i, step)
+		}
+	}
+}
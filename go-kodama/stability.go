@@ -0,0 +1,156 @@
+package kodama
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// BootstrapStability estimates how stable each cluster of a flat clustering
+// is under resampling of the observations.
+//
+// The observations are given as a row-major n x dim matrix, as in
+// CondensedMatrix. They are first clustered with the given metric and
+// method, and the resulting dendrogram is cut into k clusters with
+// FlatClustersByCount. Then, for each of the given number of iterations, n
+// observations are drawn with replacement, the distinct observations drawn
+// are reclustered and cut into (at most) k clusters, and each original
+// cluster is matched with the new cluster that has the highest Jaccard
+// similarity to it. The Jaccard similarity is computed over only the
+// observations that were drawn.
+//
+// The ith element of the returned slice is the mean of those Jaccard
+// similarities for the ith original cluster, where clusters are numbered as
+// in FlatClustersByCount(k). Scores range from 0 to 1, where values close to
+// 1 indicate the cluster is consistently recovered. An iteration that draws
+// none of a cluster's observations does not contribute to its score, and a
+// cluster that is never drawn has a score of NaN.
+//
+// The given seed makes the resampling deterministic. An error is returned
+// if the method is not valid, if the length of data is not n*dim, if k is
+// not in the range [1, n], if iterations is less than 1 or if the metric
+// cannot be computed for the data (see CondensedMatrix).
+func BootstrapStability(
+	data []float64,
+	n, dim int,
+	metric Metric,
+	method Method,
+	k, iterations int,
+	seed int64,
+) ([]float64, error) {
+	if err := checkMethod(method); err != nil {
+		return nil, err
+	}
+	if k < 1 || k > n {
+		return nil, fmt.Errorf(
+			"expected number of clusters in range [1, %d], but got %d", n, k)
+	}
+	if iterations < 1 {
+		return nil, fmt.Errorf(
+			"expected at least 1 iteration, but got %d", iterations)
+	}
+	condensed, err := CondensedMatrix(data, n, dim, metric)
+	if err != nil {
+		return nil, err
+	}
+
+	original := Linkage64Copy(condensed, n, method)
+	defer original.Close()
+	clusters := groupLabels(original.FlatClustersByCount(k))
+
+	rng := rand.New(rand.NewSource(seed))
+	sums := make([]float64, k)
+	counts := make([]int, k)
+	drawn := make([]bool, n)
+	for iter := 0; iter < iterations; iter++ {
+		for i := range drawn {
+			drawn[i] = false
+		}
+		for i := 0; i < n; i++ {
+			drawn[rng.Intn(n)] = true
+		}
+		labels := resampledLabels(condensed, n, drawn, method, k)
+		for c, members := range clusters {
+			if score, ok := bestJaccard(members, labels); ok {
+				sums[c] += score
+				counts[c]++
+			}
+		}
+	}
+
+	scores := make([]float64, k)
+	for c := range scores {
+		if counts[c] == 0 {
+			scores[c] = math.NaN()
+		} else {
+			scores[c] = sums[c] / float64(counts[c])
+		}
+	}
+	return scores, nil
+}
+
+// resampledLabels clusters only the drawn observations and cuts the result
+// into at most k clusters. The returned slice has length observations, where
+// observations that were not drawn have label -1.
+func resampledLabels(
+	condensed []float64,
+	observations int,
+	drawn []bool,
+	method Method,
+	k int,
+) []int {
+	var sample []int
+	for i, ok := range drawn {
+		if ok {
+			sample = append(sample, i)
+		}
+	}
+	m := len(sample)
+	sub := make([]float64, 0, (m*(m-1))/2)
+	for a := 0; a < m; a++ {
+		for b := a + 1; b < m; b++ {
+			sub = append(sub, condensed[condensedIndex(observations, sample[a], sample[b])])
+		}
+	}
+	dend := Linkage64(sub, m, method)
+	defer dend.Close()
+
+	labels := make([]int, observations)
+	for i := range labels {
+		labels[i] = -1
+	}
+	for a, label := range dend.FlatClustersByCount(min(k, m)) {
+		labels[sample[a]] = label
+	}
+	return labels
+}
+
+// bestJaccard returns the highest Jaccard similarity between the given
+// members of a cluster and any cluster in labels, considering only
+// observations whose label is not -1. If none of the members have a label,
+// then false is returned.
+func bestJaccard(members []int, labels []int) (float64, bool) {
+	sizes := make(map[int]int)
+	for _, label := range labels {
+		if label >= 0 {
+			sizes[label]++
+		}
+	}
+	overlaps := make(map[int]int)
+	present := 0
+	for _, i := range members {
+		if labels[i] >= 0 {
+			overlaps[labels[i]]++
+			present++
+		}
+	}
+	if present == 0 {
+		return 0, false
+	}
+	best := 0.0
+	for label, overlap := range overlaps {
+		union := present + sizes[label] - overlap
+		best = math.Max(best, float64(overlap)/float64(union))
+	}
+	return best, true
+}
@@ -0,0 +1,104 @@
+package kodama
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// twoBlobs returns two tight, well separated groups of 2-dimensional
+// observations.
+func twoBlobs() ([]float64, int) {
+	data := []float64{
+		0, 0,
+		0, 1,
+		1, 0,
+		1, 1,
+		0.5, 0.5,
+		100, 100,
+		100, 101,
+		101, 100,
+		101, 101,
+		100.5, 100.5,
+	}
+	return data, len(data) / 2
+}
+
+func TestBootstrapStability(t *testing.T) {
+	data, n := twoBlobs()
+	scores, err := BootstrapStability(data, n, 2, MetricEuclidean, MethodAverage, 2, 20, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scores) != 2 {
+		t.Fatalf("expected 2 scores, but got %v\n", scores)
+	}
+	for c, score := range scores {
+		if math.Abs(score-1) > 1e-12 {
+			t.Fatalf("cluster %d: expected stability 1, but got %v\n", c, score)
+		}
+	}
+
+	// A single cluster is always recovered perfectly.
+	scores, err = BootstrapStability(data, n, 2, MetricEuclidean, MethodAverage, 1, 5, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(scores, []float64{1}) {
+		t.Fatalf("expected [1], but got %v\n", scores)
+	}
+}
+
+func TestBootstrapStabilityUnstable(t *testing.T) {
+	// Splitting two blobs into 4 clusters is arbitrary, so at least one
+	// cluster should not be perfectly stable.
+	data, n := twoBlobs()
+	scores, err := BootstrapStability(data, n, 2, MetricEuclidean, MethodAverage, 4, 50, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unstable := false
+	for _, score := range scores {
+		if score < 0 || score > 1 {
+			t.Fatalf("expected scores in [0, 1], but got %v\n", scores)
+		}
+		unstable = unstable || score < 1
+	}
+	if !unstable {
+		t.Fatalf("expected some unstable cluster, but got %v\n", scores)
+	}
+}
+
+func TestBootstrapStabilityDeterministic(t *testing.T) {
+	data, n := twoBlobs()
+	a, err := BootstrapStability(data, n, 2, MetricEuclidean, MethodAverage, 3, 10, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := BootstrapStability(data, n, 2, MetricEuclidean, MethodAverage, 3, 10, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(a, b) {
+		t.Fatalf("expected equal scores for the same seed, but got %v and %v\n", a, b)
+	}
+}
+
+func TestBootstrapStabilityInvalid(t *testing.T) {
+	data, n := twoBlobs()
+	tests := []struct {
+		n, k, iterations int
+	}{
+		{n, 0, 10},
+		{n, n + 1, 10},
+		{n, 2, 0},
+		{n + 1, 2, 10},
+	}
+	for _, test := range tests {
+		_, err := BootstrapStability(
+			data, test.n, 2, MetricEuclidean, MethodAverage, test.k, test.iterations, 1)
+		if err == nil {
+			t.Fatalf("expected error for %+v\n", test)
+		}
+	}
+}
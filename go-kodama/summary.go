@@ -0,0 +1,96 @@
+package kodama
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// DendrogramSummary describes a dendrogram at a glance. It is returned by
+// Summary.
+type DendrogramSummary struct {
+	// Observations is the number of observations that were clustered.
+	Observations int
+	// MinDissimilarity, MaxDissimilarity and MeanDissimilarity are the
+	// smallest, largest and mean dissimilarity of the merge steps.
+	MinDissimilarity  float64
+	MaxDissimilarity  float64
+	MeanDissimilarity float64
+	// Height is the dissimilarity of the last step, which creates the
+	// cluster containing every observation. This is the same as
+	// MaxDissimilarity unless the dendrogram has inversions. For a partial
+	// dendrogram, e.g., as returned by Linkage64Until, it is the largest
+	// dissimilarity of the step that creates the root of any tree.
+	Height float64
+	// Monotonic is the result of IsMonotonic.
+	Monotonic bool
+}
+
+// Summary returns a summary of this dendrogram's merge dissimilarities.
+//
+// If this dendrogram has no steps, then every dissimilarity in the summary
+// is 0. Such a dendrogram is trivially monotonic.
+func (dend *Dendrogram) Summary() DendrogramSummary {
+	summary := DendrogramSummary{
+		Observations: dend.Observations(),
+		Monotonic:    dend.IsMonotonic(),
+	}
+	steps := dend.Steps()
+	if len(steps) == 0 {
+		return summary
+	}
+	summary.MinDissimilarity = math.Inf(1)
+	summary.MaxDissimilarity = math.Inf(-1)
+	sum := 0.0
+	for _, s := range steps {
+		summary.MinDissimilarity = math.Min(summary.MinDissimilarity, s.Dissimilarity)
+		summary.MaxDissimilarity = math.Max(summary.MaxDissimilarity, s.Dissimilarity)
+		sum += s.Dissimilarity
+	}
+	summary.MeanDissimilarity = sum / float64(len(steps))
+	summary.Height = math.Inf(-1)
+	for _, root := range dend.Roots() {
+		if root >= summary.Observations {
+			summary.Height = math.Max(summary.Height, steps[root-summary.Observations].Dissimilarity)
+		}
+	}
+	return summary
+}
+
+// Dissimilarities returns the dissimilarity of each step of this
+// dendrogram, in step order, e.g., for plotting a histogram of merge
+// heights.
+func (dend *Dendrogram) Dissimilarities() []float64 {
+	dissimilarities := make([]float64, 0, dend.Len())
+	for _, s := range dend.All() {
+		dissimilarities = append(dissimilarities, s.Dissimilarity)
+	}
+	return dissimilarities
+}
+
+// DissimilarityQuantile returns the qth quantile of the merge
+// dissimilarities of this dendrogram, e.g., 0.9 for the 90th percentile,
+// which is useful for choosing a threshold for FlatClusters from the data.
+//
+// Quantiles are computed by linear interpolation between the closest
+// dissimilarities in sorted order, which is the default method of NumPy's
+// quantile function and R's quantile function. So 0 is the smallest
+// dissimilarity, 0.5 is the median and 1 is the largest. If this dendrogram
+// has no steps, then 0 is returned, as in Summary.
+//
+// This panics if q is not in the range [0, 1].
+func (dend *Dendrogram) DissimilarityQuantile(q float64) float64 {
+	if !(q >= 0 && q <= 1) {
+		panic(fmt.Errorf("expected quantile in range [0, 1], but got %v", q))
+	}
+	dissimilarities := dend.Dissimilarities()
+	if len(dissimilarities) == 0 {
+		return 0
+	}
+	sort.Float64s(dissimilarities)
+	pos := q * float64(len(dissimilarities)-1)
+	lo := int(math.Floor(pos))
+	hi := min(lo+1, len(dissimilarities)-1)
+	frac := pos - float64(lo)
+	return dissimilarities[lo] + frac*(dissimilarities[hi]-dissimilarities[lo])
+}
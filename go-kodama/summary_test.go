@@ -0,0 +1,111 @@
+package kodama
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestSummary(t *testing.T) {
+	got := maDendrogram().Summary()
+	sum := 0.0
+	for _, s := range maSteps {
+		sum += s.Dissimilarity
+	}
+	if got.Observations != maObservations || !got.Monotonic {
+		t.Fatalf("unexpected summary %+v\n", got)
+	}
+	expected := []float64{
+		maSteps[0].Dissimilarity,
+		maSteps[len(maSteps)-1].Dissimilarity,
+		sum / float64(len(maSteps)),
+		maSteps[len(maSteps)-1].Dissimilarity,
+	}
+	assertFloatsApproxEq(t, []float64{
+		got.MinDissimilarity, got.MaxDissimilarity, got.MeanDissimilarity, got.Height,
+	}, expected)
+}
+
+func TestSummaryInversion(t *testing.T) {
+	dend, err := NewDendrogram([]Step{
+		{0, 1, 3, 2},
+		{2, 3, 1, 3},
+	}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := dend.Summary()
+	expected := DendrogramSummary{
+		Observations:      3,
+		MinDissimilarity:  1,
+		MaxDissimilarity:  3,
+		MeanDissimilarity: 2,
+		Height:            1,
+		Monotonic:         false,
+	}
+	if got != expected {
+		t.Fatalf("expected %+v, but got %+v\n", expected, got)
+	}
+}
+
+func TestSummaryEmpty(t *testing.T) {
+	for _, obs := range []int{0, 1} {
+		got := Linkage64([]float64{}, obs, MethodAverage).Summary()
+		expected := DendrogramSummary{Observations: obs, Monotonic: true}
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("expected %+v, but got %+v\n", expected, got)
+		}
+	}
+}
+
+func TestDissimilarities(t *testing.T) {
+	got := maDendrogram().Dissimilarities()
+	expected := make([]float64, len(maSteps))
+	for i, s := range maSteps {
+		expected[i] = s.Dissimilarity
+	}
+	assertFloatsApproxEq(t, got, expected)
+
+	if got := Linkage64([]float64{}, 1, MethodAverage).Dissimilarities(); len(got) != 0 {
+		t.Fatalf("expected no dissimilarities, but got %v\n", got)
+	}
+}
+
+func TestDissimilarityQuantile(t *testing.T) {
+	dend := maDendrogram()
+	tests := []struct {
+		q, expected float64
+	}{
+		{0, maSteps[0].Dissimilarity},
+		{0.25, maSteps[1].Dissimilarity},
+		{0.5, maSteps[2].Dissimilarity},
+		{1, maSteps[4].Dissimilarity},
+		// 0.9 is 60% of the way from the 4th to the 5th dissimilarity.
+		{0.9, maSteps[3].Dissimilarity + 0.6*(maSteps[4].Dissimilarity-maSteps[3].Dissimilarity)},
+	}
+	for _, test := range tests {
+		got := dend.DissimilarityQuantile(test.q)
+		assertFloatsApproxEq(t, []float64{got}, []float64{test.expected})
+	}
+
+	if got := Linkage64([]float64{}, 1, MethodAverage).DissimilarityQuantile(0.5); got != 0 {
+		t.Fatalf("expected 0 for empty dendrogram, but got %v\n", got)
+	}
+	for _, q := range []float64{-0.1, 1.1, math.NaN()} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("q=%v: expected panic\n", q)
+				}
+			}()
+			dend.DissimilarityQuantile(q)
+		}()
+	}
+}
+
+func TestSummaryForest(t *testing.T) {
+	dend := maPartialDendrogram()
+	if got := dend.Summary().Height; got != maSteps[1].Dissimilarity {
+		t.Fatalf("expected height %v, but got %v\n", maSteps[1].Dissimilarity, got)
+	}
+}
@@ -0,0 +1,226 @@
+package kodama
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Orientation indicates how a dendrogram is drawn.
+type Orientation int
+
+// The available orientations for drawing dendrograms.
+const (
+	// OrientationTopDown draws the root at the top and the leaves along
+	// the bottom.
+	OrientationTopDown Orientation = iota
+	// OrientationLeftRight draws the root on the left and the leaves along
+	// the right.
+	OrientationLeftRight
+)
+
+// SVGOptions controls how a dendrogram is rendered by SVG.
+type SVGOptions struct {
+	// Width and Height are the dimensions of the image in pixels. When
+	// zero, they default to 800 and 400, respectively.
+	Width, Height int
+	// Orientation controls which way the tree is drawn.
+	Orientation Orientation
+	// Labels names each observation, and is indexed by observation. When
+	// empty, observations are named by their index.
+	Labels []string
+	// ColorThreshold, when positive, colors the links of every flat cluster
+	// formed by FlatClusters(ColorThreshold) with its own color. Links
+	// above the threshold are drawn in black.
+	ColorThreshold float64
+}
+
+// svgPalette is the set of colors used to distinguish flat clusters.
+var svgPalette = []string{
+	"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd",
+	"#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf",
+}
+
+// svgMargin is the space in pixels left around the drawing, and
+// svgLabelSpace is the space reserved for leaf labels.
+const (
+	svgMargin     = 10.0
+	svgLabelSpace = 60.0
+)
+
+// SVG writes an SVG image of this dendrogram to w.
+//
+// Leaves are laid out according to LeafOrder and each merge is drawn at a
+// distance from the leaves proportional to its dissimilarity. If
+// opts.Labels is not empty and its length is not equal to Observations(),
+// then an error is returned. An error is also returned if writing to w
+// fails.
+func (dend *Dendrogram) SVG(w io.Writer, opts SVGOptions) error {
+	obs := dend.Observations()
+	if len(opts.Labels) > 0 && len(opts.Labels) != obs {
+		return fmt.Errorf(
+			"expected %d labels, but got %d", obs, len(opts.Labels))
+	}
+	if opts.Width == 0 {
+		opts.Width = 800
+	}
+	if opts.Height == 0 {
+		opts.Height = 400
+	}
+	width, height := float64(opts.Width), float64(opts.Height)
+
+	layout := dend.layout()
+	// point converts a position along the leaf axis and a dissimilarity
+	// into image coordinates.
+	point := func(p, d float64) (x, y float64) {
+		h := d / layout.maxHeight
+		switch opts.Orientation {
+		case OrientationLeftRight:
+			x = svgMargin + (1-h)*(width-svgMargin-svgLabelSpace)
+			y = svgMargin + p*(height-2*svgMargin)/float64(obs)
+		default:
+			x = svgMargin + p*(width-2*svgMargin)/float64(obs)
+			y = svgMargin + (1-h)*(height-svgMargin-svgLabelSpace)
+		}
+		return x, y
+	}
+
+	var colors []int
+	if opts.ColorThreshold > 0 {
+		colors = layout.colors(dend.FlatClusters(opts.ColorThreshold))
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		opts.Width, opts.Height, opts.Width, opts.Height)
+	layout.writeSVGLinks(bw, opts.Orientation, point, colors)
+	for _, o := range layout.order {
+		x, y := point(layout.pos[o], 0)
+		label := strconv.Itoa(o)
+		if len(opts.Labels) > 0 {
+			label = opts.Labels[o]
+		}
+		if opts.Orientation == OrientationLeftRight {
+			fmt.Fprintf(bw,
+				`<text x="%s" y="%s" font-size="10" dominant-baseline="middle">`,
+				svgNum(x+4), svgNum(y))
+		} else {
+			fmt.Fprintf(bw,
+				`<text x="%s" y="%s" font-size="10" text-anchor="end" transform="rotate(-90 %s %s)" dominant-baseline="middle">`,
+				svgNum(x), svgNum(y+4), svgNum(x), svgNum(y+4))
+		}
+		xml.EscapeText(bw, []byte(label))
+		bw.WriteString("</text>\n")
+	}
+	bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
+
+// svgNum formats a coordinate compactly.
+func svgNum(x float64) string {
+	return strconv.FormatFloat(x, 'f', 2, 64)
+}
+
+// dendrogramLayout is the position of every cluster in a drawing of a
+// dendrogram.
+type dendrogramLayout struct {
+	observations int
+	steps        []Step
+	// order is the leaf order.
+	order []int
+	// pos maps every cluster label to its position along the leaf axis.
+	// The ith leaf in order is at i + 0.5, and every merged cluster is
+	// halfway between its children.
+	pos []float64
+	// maxHeight is the largest dissimilarity of any step, or 1 if there
+	// are no positive dissimilarities.
+	maxHeight float64
+}
+
+// layout computes the position of every cluster in a drawing of this
+// dendrogram.
+func (dend *Dendrogram) layout() *dendrogramLayout {
+	obs := dend.Observations()
+	l := &dendrogramLayout{
+		observations: obs,
+		steps:        dend.Steps(),
+		order:        dend.LeafOrder(),
+	}
+	l.pos = make([]float64, obs+len(l.steps))
+	for i, o := range l.order {
+		l.pos[o] = float64(i) + 0.5
+	}
+	for i, s := range l.steps {
+		l.pos[obs+i] = (l.pos[s.Cluster1] + l.pos[s.Cluster2]) / 2
+		if s.Dissimilarity > l.maxHeight {
+			l.maxHeight = s.Dissimilarity
+		}
+	}
+	if l.maxHeight <= 0 {
+		l.maxHeight = 1
+	}
+	return l
+}
+
+// writeSVGLinks writes an SVG path for every step of this layout to w.
+// point converts a position along the leaf axis and a dissimilarity into
+// image coordinates, and colors is either nil or the result of colors.
+func (l *dendrogramLayout) writeSVGLinks(
+	w *bufio.Writer,
+	orientation Orientation,
+	point func(p, d float64) (x, y float64),
+	colors []int,
+) {
+	for i, s := range l.steps {
+		x1, y1 := point(l.pos[s.Cluster1], l.height(s.Cluster1))
+		x2, y2 := point(l.pos[s.Cluster2], l.height(s.Cluster2))
+		var path string
+		if orientation == OrientationLeftRight {
+			x, _ := point(0, s.Dissimilarity)
+			path = fmt.Sprintf("M%s %s H%s V%s H%s",
+				svgNum(x1), svgNum(y1), svgNum(x), svgNum(y2), svgNum(x2))
+		} else {
+			_, y := point(0, s.Dissimilarity)
+			path = fmt.Sprintf("M%s %s V%s H%s V%s",
+				svgNum(x1), svgNum(y1), svgNum(y), svgNum(x2), svgNum(y2))
+		}
+		color := "#000000"
+		if colors != nil && colors[i] >= 0 {
+			color = svgPalette[colors[i]%len(svgPalette)]
+		}
+		fmt.Fprintf(w, `<path d="%s" fill="none" stroke="%s"/>`+"\n", path, color)
+	}
+}
+
+// height returns the dissimilarity at which the given cluster is drawn.
+// Leaves are drawn at zero.
+func (l *dendrogramLayout) height(label int) float64 {
+	if label < l.observations {
+		return 0
+	}
+	return l.steps[label-l.observations].Dissimilarity
+}
+
+// colors returns, for each step, the label of the flat cluster it belongs
+// to in the given flat clustering, or -1 if the step joins two different
+// flat clusters.
+func (l *dendrogramLayout) colors(flat []int) []int {
+	obs := l.observations
+	colors := make([]int, len(l.steps))
+	// leaf[label] is any observation in the cluster with the given label.
+	leaf := make([]int, obs+len(l.steps))
+	for i := 0; i < obs; i++ {
+		leaf[i] = i
+	}
+	for i, s := range l.steps {
+		leaf[obs+i] = leaf[s.Cluster1]
+		colors[i] = -1
+		if flat[leaf[s.Cluster1]] == flat[leaf[s.Cluster2]] {
+			colors[i] = flat[leaf[s.Cluster1]]
+		}
+	}
+	return colors
+}
@@ -0,0 +1,83 @@
+package kodama
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSVG(t *testing.T) {
+	dend := maDendrogram()
+	labels := []string{
+		"fitchburg", "framingham", "marlborough",
+		"northbridge", "southborough", "westborough & co",
+	}
+	for _, orientation := range []Orientation{OrientationTopDown, OrientationLeftRight} {
+		var buf bytes.Buffer
+		err := dend.SVG(&buf, SVGOptions{
+			Orientation:    orientation,
+			Labels:         labels,
+			ColorThreshold: 10,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertWellFormedXML(t, buf.Bytes())
+		out := buf.String()
+		if got := strings.Count(out, "<path"); got != maObservations-1 {
+			t.Fatalf("expected %d links, but got %d\n", maObservations-1, got)
+		}
+		if !strings.Contains(out, "westborough &amp; co") {
+			t.Fatal("expected escaped label")
+		}
+		// The clusters below 10 get palette colors, while the two merges
+		// above it are black.
+		if got := strings.Count(out, `stroke="#000000"`); got != 2 {
+			t.Fatalf("expected 2 uncolored links, but got %d\n", got)
+		}
+	}
+}
+
+func TestSVGTrivial(t *testing.T) {
+	for _, obs := range []int{0, 1} {
+		var buf bytes.Buffer
+		dend := Linkage64([]float64{}, obs, MethodAverage)
+		if err := dend.SVG(&buf, SVGOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		assertWellFormedXML(t, buf.Bytes())
+	}
+	var buf bytes.Buffer
+	if err := maDendrogram().SVG(&buf, SVGOptions{Labels: []string{"a"}}); err == nil {
+		t.Fatal("expected error for mismatched number of labels")
+	}
+}
+
+func assertWellFormedXML(t *testing.T, data []byte) {
+	t.Helper()
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			t.Fatalf("malformed XML: %v\n%s", err, data)
+		}
+	}
+}
+
+func TestSVGLayoutForest(t *testing.T) {
+	// Every observation gets its own position along the leaf axis, even
+	// those that were never merged.
+	layout := maPartialDendrogram().layout()
+	seen := make(map[float64]bool)
+	for o := 0; o < maObservations; o++ {
+		if seen[layout.pos[o]] {
+			t.Fatalf("observation %d shares position %v\n", o, layout.pos[o])
+		}
+		seen[layout.pos[o]] = true
+	}
+}
@@ -0,0 +1,531 @@
+package kodama
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// LeafOrder returns the observations of this dendrogram in the order they
+// appear from left to right in a standard dendrogram plot, such that the
+// lines connecting clusters do not cross.
+//
+// The order is given by a depth first traversal of the merge tree starting
+// at the root, where for each step, the observations under Cluster1 are
+// visited before those under Cluster2. This matches the leaf order produced
+// by SciPy's dendrogram function. A partial dendrogram, e.g., as returned by
+// Linkage64Until, has one tree per root, and the orders of its trees are
+// concatenated in the order of Roots.
+func (dend *Dendrogram) LeafOrder() []int {
+	obs := dend.Observations()
+	steps := dend.Steps()
+	order := make([]int, 0, obs)
+	// Use an explicit stack, since the tree may be as deep as the number
+	// of observations. Roots are pushed in reverse, so the first is
+	// visited first.
+	roots := dend.Roots()
+	stack := make([]int, 0, len(roots))
+	for i := len(roots) - 1; i >= 0; i-- {
+		stack = append(stack, roots[i])
+	}
+	for len(stack) > 0 {
+		label := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if label < obs {
+			order = append(order, label)
+			continue
+		}
+		s := steps[label-obs]
+		stack = append(stack, s.Cluster2, s.Cluster1)
+	}
+	return order
+}
+
+// OptimalLeafOrder returns a leaf order for this dendrogram that minimizes
+// the sum of the dissimilarities between adjacent leaves, which tends to make
+// heatmaps of the reordered dissimilarity matrix far more readable.
+//
+// Like LeafOrder, the returned order is consistent with the merge tree: the
+// observations of every cluster appear contiguously. Only the order in
+// which the two children of each step are laid out may differ. This uses
+// the algorithm described by Bar-Joseph, Gifford and Jaakkola in "Fast
+// optimal leaf ordering for hierarchical clustering," which runs in
+// O(observations^3) time and O(observations^2) memory. When there are
+// multiple optimal orders, the one found first when visiting leaves in
+// LeafOrder is returned. The trees of a partial dendrogram, e.g., as
+// returned by Linkage64Until, are each ordered optimally on their own and
+// then concatenated in the order of Roots.
+//
+// The given condensed matrix should contain the original dissimilarities
+// used to build this dendrogram. If its length is not observations-choose-2,
+// then an error is returned.
+func (dend *Dendrogram) OptimalLeafOrder(condensed []float64) ([]int, error) {
+	obs := dend.Observations()
+	if err := checkMatrixLen(len(condensed), obs); err != nil {
+		return nil, err
+	}
+	order := dend.LeafOrder()
+	if obs <= 2 {
+		return order, nil
+	}
+	steps := dend.Steps()
+	dist := func(a, b int) float64 {
+		if a > b {
+			a, b = b, a
+		}
+		return condensed[condensedIndex(obs, a, b)]
+	}
+
+	// In LeafOrder, the leaves of every cluster are contiguous, so each
+	// cluster is represented by its span [start, end) in order.
+	pos := make([]int, obs)
+	for i, o := range order {
+		pos[o] = i
+	}
+	start := make([]int, obs+len(steps))
+	end := make([]int, obs+len(steps))
+	for o := 0; o < obs; o++ {
+		start[o], end[o] = pos[o], pos[o]+1
+	}
+	for i, s := range steps {
+		start[obs+i], end[obs+i] = start[s.Cluster1], end[s.Cluster2]
+	}
+	leaves := func(label int) []int {
+		return order[start[label]:end[label]]
+	}
+	contains := func(label, leaf int) bool {
+		return start[label] <= pos[leaf] && pos[leaf] < end[label]
+	}
+	// opposite returns the leaves of the given cluster that may appear at
+	// the other end of an ordering of it that starts with leaf.
+	opposite := func(label, leaf int) []int {
+		if label < obs {
+			return leaves(label)
+		}
+		s := steps[label-obs]
+		if contains(s.Cluster1, leaf) {
+			return leaves(s.Cluster2)
+		}
+		return leaves(s.Cluster1)
+	}
+
+	// cost[u][w] is the minimum cost of ordering the smallest cluster
+	// containing both u and w, such that the ordering starts with u and
+	// ends with w. It is zero when u == w.
+	cost := make([][]float64, obs)
+	for i := range cost {
+		cost[i] = make([]float64, obs)
+	}
+	// best[k] is the minimum cost of ordering the left cluster starting
+	// at some u and then stepping over to k in the right cluster.
+	best := make([]float64, obs)
+	for _, s := range steps {
+		left, right := leaves(s.Cluster1), leaves(s.Cluster2)
+		for _, u := range left {
+			oppositeU := opposite(s.Cluster1, u)
+			for _, k := range right {
+				best[k] = math.Inf(1)
+				for _, m := range oppositeU {
+					if c := cost[u][m] + dist(m, k); c < best[k] {
+						best[k] = c
+					}
+				}
+			}
+			for _, w := range right {
+				lowest := math.Inf(1)
+				for _, k := range opposite(s.Cluster2, w) {
+					if c := best[k] + cost[k][w]; c < lowest {
+						lowest = c
+					}
+				}
+				cost[u][w], cost[w][u] = lowest, lowest
+			}
+		}
+	}
+
+	// Pick the best pair of ends for each root and then retrace the choices
+	// that led to its cost. The trees of a partial dendrogram are ordered
+	// independently and laid out in the order of Roots, as in LeafOrder.
+	optimal := make([]int, 0, obs)
+	var build func(label, u, w int)
+	build = func(label, u, w int) {
+		if label < obs {
+			optimal = append(optimal, u)
+			return
+		}
+		s := steps[label-obs]
+		first, second := s.Cluster1, s.Cluster2
+		if !contains(first, u) {
+			first, second = second, first
+		}
+		bestM, bestK, lowest := -1, -1, math.Inf(1)
+		for _, m := range opposite(first, u) {
+			for _, k := range opposite(second, w) {
+				if c := cost[u][m] + dist(m, k) + cost[k][w]; c < lowest {
+					bestM, bestK, lowest = m, k, c
+				}
+			}
+		}
+		build(first, u, bestM)
+		build(second, bestK, w)
+	}
+	for _, root := range dend.Roots() {
+		if root < obs {
+			optimal = append(optimal, root)
+			continue
+		}
+		rootStep := steps[root-obs]
+		bestU, bestW, lowest := -1, -1, math.Inf(1)
+		for _, u := range leaves(rootStep.Cluster1) {
+			for _, w := range leaves(rootStep.Cluster2) {
+				if cost[u][w] < lowest {
+					bestU, bestW, lowest = u, w, cost[u][w]
+				}
+			}
+		}
+		build(root, bestU, bestW)
+	}
+	return optimal, nil
+}
+
+// Ladderize returns a new dendrogram with the children of every step
+// ordered by size, which makes plots of the tree look tidier. If ascending
+// is true, then the smaller of the two clusters merged by each step is
+// Cluster1 and is laid out first by LeafOrder. Otherwise, the larger one
+// is. Steps that merge clusters of equal size are left unchanged.
+//
+// This only changes the presentation of the dendrogram. Every step still
+// merges the same two clusters at the same dissimilarity, so cluster
+// labels and membership are unchanged. However, ladderized steps may have
+// Cluster1 greater than Cluster2, unlike in dendrograms produced by
+// clustering. They are accepted by NewDendrogram, and the returned
+// dendrogram round trips through MarshalJSON and GobEncode, but they are
+// rejected by ValidateSteps.
+func (dend *Dendrogram) Ladderize(ascending bool) *Dendrogram {
+	obs := dend.Observations()
+	steps := dend.Steps()
+	size := func(label int) int {
+		if label < obs {
+			return 1
+		}
+		return steps[label-obs].Size
+	}
+	for i, s := range steps {
+		size1, size2 := size(s.Cluster1), size(s.Cluster2)
+		if (ascending && size1 > size2) || (!ascending && size1 < size2) {
+			steps[i].Cluster1, steps[i].Cluster2 = s.Cluster2, s.Cluster1
+		}
+	}
+	ladderized := &Dendrogram{}
+	ladderized.setSteps(steps, obs)
+	return ladderized
+}
+
+// ClustersBySize returns the members of the largest clusters in this
+// dendrogram whose sizes are in the range [minSize, maxSize].
+//
+// Unlike cutting the dendrogram at a single threshold, every cluster at
+// every level of the tree is considered, including each observation as a
+// cluster of size 1. A cluster is only returned if none of the clusters
+// containing it are also in range, so the returned clusters never overlap.
+// Each cluster is a sorted slice of observation indices, and the clusters
+// are ordered by their smallest member. If no clusters are in range, then an
+// empty slice is returned.
+func (dend *Dendrogram) ClustersBySize(minSize, maxSize int) [][]int {
+	obs := dend.Observations()
+	steps := dend.Steps()
+	// owner[label] is the index of the returned cluster containing the
+	// cluster with the given label, or -1 if it is not contained in any.
+	// Every step merges clusters with smaller labels, so visiting labels in
+	// descending order visits every cluster before the clusters inside it.
+	owner := make([]int, obs+len(steps))
+	for i := range owner {
+		owner[i] = -1
+	}
+	count := 0
+	for label := obs + len(steps) - 1; label >= 0; label-- {
+		size := 1
+		if label >= obs {
+			size = steps[label-obs].Size
+		}
+		if owner[label] == -1 && size >= minSize && size <= maxSize {
+			owner[label] = count
+			count++
+		}
+		if label >= obs {
+			s := steps[label-obs]
+			owner[s.Cluster1], owner[s.Cluster2] = owner[label], owner[label]
+		}
+	}
+	// Renumber the clusters in order of their smallest member.
+	order := make([]int, count)
+	for i := range order {
+		order[i] = -1
+	}
+	sorted := [][]int{}
+	for o := 0; o < obs; o++ {
+		c := owner[o]
+		if c == -1 {
+			continue
+		}
+		if order[c] == -1 {
+			order[c] = len(sorted)
+			sorted = append(sorted, nil)
+		}
+		sorted[order[c]] = append(sorted[order[c]], o)
+	}
+	return sorted
+}
+
+// MembershipBitsets returns the members of the cluster created by each step
+// of this dendrogram as a bitset, which supports fast set operations, e.g.,
+// to check whether a cluster contains any of a set of observations with And.
+//
+// The ith element of the returned slice corresponds to the cluster created
+// by the ith step, i.e., the cluster with label Observations() + i. Bit j of
+// that element is set if and only if observation j is a member of the
+// cluster. Each bitset takes at most about Observations() / 8 bytes, which
+// is a small fraction of the memory needed to store the same members as a
+// slice of ints.
+func (dend *Dendrogram) MembershipBitsets() []*big.Int {
+	obs := dend.Observations()
+	steps := dend.Steps()
+	bitsets := make([]*big.Int, len(steps))
+	members := func(label int) *big.Int {
+		if label < obs {
+			return new(big.Int).SetBit(new(big.Int), label, 1)
+		}
+		return bitsets[label-obs]
+	}
+	for i, s := range steps {
+		bitsets[i] = new(big.Int).Or(members(s.Cluster1), members(s.Cluster2))
+	}
+	return bitsets
+}
+
+// TreeNode is a node in the binary tree representation of a dendrogram
+// returned by Tree.
+type TreeNode struct {
+	// Label is the cluster label of this node. Leaves are labeled by their
+	// observation index, and the node created by the ith step of the
+	// dendrogram is labeled Observations() + i.
+	Label int
+	// Height is the dissimilarity at which this node's children were
+	// merged, or 0 for a leaf.
+	Height float64
+	// Size is the number of observations beneath this node, which is 1 for
+	// a leaf.
+	Size int
+	// Left and Right are this node's children, corresponding to the
+	// Cluster1 and Cluster2 fields of the step that created it. Both are
+	// nil for a leaf.
+	Left, Right *TreeNode
+}
+
+// IsLeaf returns true if and only if this node is an observation, i.e., it
+// has no children.
+func (node *TreeNode) IsLeaf() bool {
+	return node.Left == nil
+}
+
+// Tree returns the root of a pointer-based binary tree equivalent to this
+// dendrogram, which may be more convenient than steps for recursive
+// traversals.
+//
+// The entire tree is materialized at once, with one node for every
+// observation and every step. Nodes are not shared with this dendrogram, so
+// they may be modified freely. If this dendrogram has no observations, then
+// nil is returned. If it is a partial dendrogram with more than one tree
+// (see Roots), then this panics. Use Trees instead.
+func (dend *Dendrogram) Tree() *TreeNode {
+	trees := dend.Trees()
+	switch len(trees) {
+	case 0:
+		return nil
+	case 1:
+		return trees[0]
+	default:
+		panic(fmt.Errorf(
+			"expected a single tree, but got %d; use Trees for partial dendrograms",
+			len(trees)))
+	}
+}
+
+// Trees is like Tree, except it returns the root of every tree of this
+// dendrogram, in the order of Roots. This is a single tree for a complete
+// dendrogram of at least one observation, but a partial dendrogram, e.g.,
+// as returned by Linkage64Until, may have many. If this dendrogram has no
+// observations, then an empty slice is returned.
+func (dend *Dendrogram) Trees() []*TreeNode {
+	obs := dend.Observations()
+	steps := dend.Steps()
+	nodes := make([]TreeNode, obs+len(steps))
+	for i := 0; i < obs; i++ {
+		nodes[i] = TreeNode{Label: i, Size: 1}
+	}
+	for i, s := range steps {
+		nodes[obs+i] = TreeNode{
+			Label:  obs + i,
+			Height: s.Dissimilarity,
+			Size:   s.Size,
+			Left:   &nodes[s.Cluster1],
+			Right:  &nodes[s.Cluster2],
+		}
+	}
+	roots := dend.Roots()
+	trees := make([]*TreeNode, len(roots))
+	for i, root := range roots {
+		trees[i] = &nodes[root]
+	}
+	return trees
+}
+
+// TraversalOrder indicates the order in which Walk visits the nodes of a
+// dendrogram.
+type TraversalOrder int
+
+// The available traversal orders. In every order, a node's Left child is
+// visited before its Right child.
+const (
+	// TraversalPreOrder visits each node before the nodes beneath it,
+	// depth first.
+	TraversalPreOrder TraversalOrder = iota
+	// TraversalPostOrder visits each node after the nodes beneath it,
+	// depth first.
+	TraversalPostOrder
+	// TraversalLevelOrder visits the nodes breadth first, starting at the
+	// root, such that every node at a given depth is visited before any
+	// node deeper in the tree.
+	TraversalLevelOrder
+)
+
+// Walk visits every node of this dendrogram in the given order, as
+// materialized by Tree.
+//
+// Each node passed to visit has its Label, Height and Size, along with its
+// Left and Right children, which are nil if and only if the node is a leaf
+// (see IsLeaf). Returning false from visit prunes the node's subtree, so
+// none of the nodes beneath it are visited. In TraversalPostOrder, the
+// nodes beneath a node have already been visited by the time it is, so
+// the return value of visit has no effect.
+//
+// The tree is materialized with a single allocation, and traversal uses an
+// explicit stack or queue, so deep trees are handled without recursion. If
+// this dendrogram has no observations, then visit is never called. Every
+// tree of a partial dendrogram is visited, in the order of Roots, where
+// TraversalLevelOrder visits the nodes at each depth of every tree before
+// any deeper node. This panics if order is not a valid TraversalOrder.
+func (dend *Dendrogram) Walk(order TraversalOrder, visit func(node TreeNode) bool) {
+	if order < TraversalPreOrder || order > TraversalLevelOrder {
+		panic(fmt.Errorf("unrecognized traversal order: %d", int(order)))
+	}
+	roots := dend.Trees()
+	switch order {
+	case TraversalPreOrder:
+		stack := make([]*TreeNode, 0, len(roots))
+		for i := len(roots) - 1; i >= 0; i-- {
+			stack = append(stack, roots[i])
+		}
+		for len(stack) > 0 {
+			node := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if visit(*node) && !node.IsLeaf() {
+				stack = append(stack, node.Right, node.Left)
+			}
+		}
+	case TraversalPostOrder:
+		// A node is expanded the first time it is popped, and visited the
+		// second time, after everything beneath it.
+		type frame struct {
+			node     *TreeNode
+			expanded bool
+		}
+		stack := make([]frame, 0, len(roots))
+		for i := len(roots) - 1; i >= 0; i-- {
+			stack = append(stack, frame{node: roots[i]})
+		}
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if top.expanded || top.node.IsLeaf() {
+				visit(*top.node)
+				continue
+			}
+			stack = append(stack,
+				frame{node: top.node, expanded: true},
+				frame{node: top.node.Right},
+				frame{node: top.node.Left})
+		}
+	case TraversalLevelOrder:
+		queue := roots
+		for head := 0; head < len(queue); head++ {
+			node := queue[head]
+			if visit(*node) && !node.IsLeaf() {
+				queue = append(queue, node.Left, node.Right)
+			}
+		}
+	}
+}
+
+// Subtree returns the part of this dendrogram beneath the cluster with the
+// given label as a new dendrogram, along with a mapping from the
+// observations of the new dendrogram to the observations of this one.
+//
+// The label must correspond to a merged cluster, i.e., it must be in the
+// range [Observations(), Observations() + Len()). Otherwise, an error is
+// returned.
+//
+// The new dendrogram has one observation for each member of the cluster,
+// where its ith observation is observation mapping[i] in this dendrogram.
+// The mapping is sorted in ascending order. The steps of the new dendrogram
+// are the steps beneath the given cluster, in the same order as they appear
+// in this dendrogram, relabeled for the new observations. The new
+// dendrogram is backed entirely by Go memory.
+func (dend *Dendrogram) Subtree(clusterLabel int) (*Dendrogram, []int, error) {
+	obs := dend.Observations()
+	steps := dend.Steps()
+	if clusterLabel < obs || clusterLabel >= obs+len(steps) {
+		return nil, nil, fmt.Errorf(
+			"expected cluster label in range [%d, %d), but got %d",
+			obs, obs+len(steps), clusterLabel)
+	}
+
+	// Find every step and observation beneath the given cluster.
+	inside := make([]bool, obs+len(steps))
+	inside[clusterLabel] = true
+	for i := clusterLabel - obs; i >= 0; i-- {
+		if inside[obs+i] {
+			inside[steps[i].Cluster1] = true
+			inside[steps[i].Cluster2] = true
+		}
+	}
+
+	// Assign new labels, first to observations and then to steps, each in
+	// ascending order.
+	newLabels := make([]int, obs+len(steps))
+	var mapping []int
+	for o := 0; o < obs; o++ {
+		if inside[o] {
+			newLabels[o] = len(mapping)
+			mapping = append(mapping, o)
+		}
+	}
+	var subSteps []Step
+	for i, s := range steps {
+		if !inside[obs+i] {
+			continue
+		}
+		newLabels[obs+i] = len(mapping) + len(subSteps)
+		subSteps = append(subSteps, Step{
+			Cluster1:      newLabels[s.Cluster1],
+			Cluster2:      newLabels[s.Cluster2],
+			Dissimilarity: s.Dissimilarity,
+			Size:          s.Size,
+		})
+	}
+	sub, err := NewDendrogram(subSteps, len(mapping))
+	if err != nil {
+		return nil, nil, err
+	}
+	return sub, mapping, nil
+}
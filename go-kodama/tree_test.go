@@ -0,0 +1,416 @@
+package kodama
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestLeafOrder(t *testing.T) {
+	got := maDendrogram().LeafOrder()
+	expected := []int{0, 3, 1, 5, 2, 4}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, but got %v\n", expected, got)
+	}
+}
+
+func TestLeafOrderTrivial(t *testing.T) {
+	for obs, expected := range [][]int{{}, {0}} {
+		got := Linkage64([]float64{}, obs, MethodAverage).LeafOrder()
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("expected %v, but got %v\n", expected, got)
+		}
+	}
+}
+
+func TestLadderize(t *testing.T) {
+	dend := maDendrogram()
+	// Every step of the MA dendrogram already puts the smaller cluster
+	// first.
+	if got := dend.Ladderize(true); !got.Equal(dend, 0) {
+		t.Fatalf("expected %v, but got %v\n", dend.Steps(), got.Steps())
+	}
+
+	got := dend.Ladderize(false)
+	expected := []int{2, 4, 5, 1, 3, 0}
+	if order := got.LeafOrder(); !reflect.DeepEqual(order, expected) {
+		t.Fatalf("expected %v, but got %v\n", expected, order)
+	}
+	if _, err := NewDendrogram(got.Steps(), maObservations); err != nil {
+		t.Fatal(err)
+	}
+	// Membership is unchanged.
+	for k := 1; k <= maObservations; k++ {
+		if a, b := dend.FlatClustersByCount(k), got.FlatClustersByCount(k); !reflect.DeepEqual(a, b) {
+			t.Fatalf("k=%d: expected %v, but got %v\n", k, a, b)
+		}
+	}
+	// Ladderizing back restores the original dendrogram.
+	if back := got.Ladderize(true); !back.Equal(dend, 0) {
+		t.Fatalf("expected %v, but got %v\n", dend.Steps(), back.Steps())
+	}
+}
+
+func TestLadderizeRoundTrip(t *testing.T) {
+	dend := maDendrogram().Ladderize(false)
+	swapped := false
+	for _, s := range dend.Steps() {
+		swapped = swapped || s.Cluster1 > s.Cluster2
+	}
+	if !swapped {
+		t.Fatalf("expected a step with cluster1 > cluster2, but got %v\n", dend.Steps())
+	}
+
+	data, err := json.Marshal(dend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromJSON Dendrogram
+	if err := json.Unmarshal(data, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	assertSameDendrogram(t, &fromJSON, dend)
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(dend); err != nil {
+		t.Fatal(err)
+	}
+	var fromGob *Dendrogram
+	if err := gob.NewDecoder(&buf).Decode(&fromGob); err != nil {
+		t.Fatal(err)
+	}
+	assertSameDendrogram(t, fromGob, dend)
+	if a, b := fromGob.LeafOrder(), dend.LeafOrder(); !reflect.DeepEqual(a, b) {
+		t.Fatalf("expected leaf order %v, but got %v\n", b, a)
+	}
+}
+
+func TestLadderizeTrivial(t *testing.T) {
+	for obs := 0; obs <= 1; obs++ {
+		got := Linkage64([]float64{}, obs, MethodAverage).Ladderize(true)
+		if got.Len() != 0 || got.Observations() != obs {
+			t.Fatalf("expected empty dendrogram of %d observations, but got %v\n",
+				obs, got.Steps())
+		}
+	}
+}
+
+func TestOptimalLeafOrder(t *testing.T) {
+	dend := maDendrogram()
+	got, err := dend.OptimalLeafOrder(maCondensedMatrix64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Brute force every order consistent with the tree by flipping the
+	// children of each step.
+	steps := dend.Steps()
+	var orders func(label int) [][]int
+	orders = func(label int) [][]int {
+		if label < maObservations {
+			return [][]int{{label}}
+		}
+		s := steps[label-maObservations]
+		var all [][]int
+		for _, a := range orders(s.Cluster1) {
+			for _, b := range orders(s.Cluster2) {
+				all = append(all, append(append([]int{}, a...), b...))
+				all = append(all, append(append([]int{}, b...), a...))
+			}
+		}
+		return all
+	}
+	lowest := math.Inf(1)
+	consistent := false
+	for _, order := range orders(maObservations + len(steps) - 1) {
+		if c := leafOrderCost(order); c < lowest {
+			lowest = c
+		}
+		if reflect.DeepEqual(order, got) {
+			consistent = true
+		}
+	}
+	if !consistent {
+		t.Fatalf("order %v is not consistent with the tree\n", got)
+	}
+	if c := leafOrderCost(got); math.Abs(c-lowest) > 0.000001 {
+		t.Fatalf("expected optimal cost %f, but got %f for %v\n", lowest, c, got)
+	}
+	if c := leafOrderCost(dend.LeafOrder()); c < lowest {
+		t.Fatalf("default order cost %f is lower than optimal %f\n", c, lowest)
+	}
+}
+
+func TestOptimalLeafOrderBadLength(t *testing.T) {
+	if _, err := maDendrogram().OptimalLeafOrder(maCondensedMatrix64[1:]); err == nil {
+		t.Fatal("expected error for mismatched matrix length")
+	}
+}
+
+// leafOrderCost returns the sum of the dissimilarities between adjacent
+// leaves in the given order of the Massachusetts test data.
+func leafOrderCost(order []int) float64 {
+	cost := 0.0
+	for i := 1; i < len(order); i++ {
+		cost += maCondensedMatrix64[CondensedIndex(maObservations, order[i-1], order[i])]
+	}
+	return cost
+}
+
+func TestClustersBySize(t *testing.T) {
+	dend := maDendrogram()
+	tests := []struct {
+		minSize, maxSize int
+		expected         [][]int
+	}{
+		{1, 1, [][]int{{0}, {1}, {2}, {3}, {4}, {5}}},
+		{1, 2, [][]int{{0}, {1}, {2, 4}, {3}, {5}}},
+		{2, 3, [][]int{{2, 4, 5}}},
+		{4, 5, [][]int{{1, 2, 3, 4, 5}}},
+		{1, 6, [][]int{{0, 1, 2, 3, 4, 5}}},
+		{7, 9, [][]int{}},
+		{3, 2, [][]int{}},
+	}
+	for _, test := range tests {
+		got := dend.ClustersBySize(test.minSize, test.maxSize)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Fatalf("[%d, %d]: expected %v, but got %v\n",
+				test.minSize, test.maxSize, test.expected, got)
+		}
+	}
+}
+
+func TestSubtree(t *testing.T) {
+	dend := maDendrogram()
+	// Label 8 is {framingham, marlborough, southborough, westborough}.
+	sub, mapping, err := dend.Subtree(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []int{1, 2, 4, 5}; !reflect.DeepEqual(mapping, expected) {
+		t.Fatalf("expected mapping %v, but got %v\n", expected, mapping)
+	}
+	expected := []Step{
+		{1, 2, 3.1237967760688776, 2},
+		{3, 4, 5.757158112027513, 3},
+		{0, 5, 8.1392602685723, 4},
+	}
+	steps := sub.Steps()
+	if sub.Observations() != 4 || len(steps) != len(expected) {
+		t.Fatalf("expected %v, but got %v\n", expected, steps)
+	}
+	for i := range steps {
+		assertStepApproxEq(t, i, steps[i], expected[i])
+	}
+
+	for _, label := range []int{maObservations - 1, 2*maObservations - 1} {
+		if _, _, err := dend.Subtree(label); err == nil {
+			t.Fatalf("expected error for label %d\n", label)
+		}
+	}
+}
+
+func TestMembershipBitsets(t *testing.T) {
+	dend := maDendrogram()
+	bitsets := dend.MembershipBitsets()
+	if len(bitsets) != len(maSteps) {
+		t.Fatalf("expected %d bitsets, but got %d\n", len(maSteps), len(bitsets))
+	}
+	// Step 1 merges observation 5 with {2, 4}, and the last step contains
+	// every observation.
+	if got := bitsets[1].Int64(); got != 1<<2|1<<4|1<<5 {
+		t.Fatalf("expected bitset %b, but got %b\n", 1<<2|1<<4|1<<5, got)
+	}
+	if got := bitsets[len(bitsets)-1].Int64(); got != 1<<maObservations-1 {
+		t.Fatalf("expected every observation, but got %b\n", got)
+	}
+	for i, bitset := range bitsets {
+		if got := bitset.BitLen(); got > maObservations {
+			t.Fatalf("step %d: expected at most %d bits, but got %d\n",
+				i, maObservations, got)
+		}
+		count := 0
+		for j := 0; j < maObservations; j++ {
+			count += int(bitset.Bit(j))
+		}
+		if count != maSteps[i].Size {
+			t.Fatalf("step %d: expected %d members, but got %d\n",
+				i, maSteps[i].Size, count)
+		}
+	}
+
+	if got := Linkage64([]float64{}, 1, MethodAverage).MembershipBitsets(); len(got) != 0 {
+		t.Fatalf("expected no bitsets, but got %v\n", got)
+	}
+}
+
+func TestTree(t *testing.T) {
+	dend := maDendrogram()
+	root := dend.Tree()
+	if root.Label != 2*maObservations-2 || root.Size != maObservations {
+		t.Fatalf("expected root %d of size %d, but got %d of size %d\n",
+			2*maObservations-2, maObservations, root.Label, root.Size)
+	}
+
+	// A depth first traversal visits the leaves in LeafOrder, and every
+	// node agrees with the step that created it.
+	steps := dend.Steps()
+	var leaves []int
+	var visit func(node *TreeNode)
+	visit = func(node *TreeNode) {
+		if node.IsLeaf() {
+			if node.Right != nil || node.Height != 0 || node.Size != 1 {
+				t.Fatalf("malformed leaf %+v\n", node)
+			}
+			leaves = append(leaves, node.Label)
+			return
+		}
+		s := steps[node.Label-maObservations]
+		if node.Left.Label != s.Cluster1 || node.Right.Label != s.Cluster2 ||
+			node.Height != s.Dissimilarity || node.Size != s.Size {
+			t.Fatalf("node %d: expected step %v, but got %+v\n", node.Label, s, node)
+		}
+		visit(node.Left)
+		visit(node.Right)
+	}
+	visit(root)
+	if expected := dend.LeafOrder(); !reflect.DeepEqual(leaves, expected) {
+		t.Fatalf("expected leaves %v, but got %v\n", expected, leaves)
+	}
+}
+
+func TestTreeTrivial(t *testing.T) {
+	if root := Linkage64([]float64{}, 0, MethodAverage).Tree(); root != nil {
+		t.Fatalf("expected nil tree, but got %+v\n", root)
+	}
+	root := Linkage64([]float64{}, 1, MethodAverage).Tree()
+	expected := &TreeNode{Label: 0, Size: 1}
+	if !reflect.DeepEqual(root, expected) {
+		t.Fatalf("expected %+v, but got %+v\n", expected, root)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	// (0, 1) at 1, then (2, 3) at 2, then both at 3.
+	dend, err := NewDendrogram([]Step{
+		{0, 1, 1, 2},
+		{2, 3, 2, 2},
+		{4, 5, 3, 4},
+	}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		order    TraversalOrder
+		prune    int
+		expected []int
+	}{
+		{TraversalPreOrder, -1, []int{6, 4, 0, 1, 5, 2, 3}},
+		{TraversalPostOrder, -1, []int{0, 1, 4, 2, 3, 5, 6}},
+		{TraversalLevelOrder, -1, []int{6, 4, 5, 0, 1, 2, 3}},
+		{TraversalPreOrder, 4, []int{6, 4, 5, 2, 3}},
+		{TraversalLevelOrder, 4, []int{6, 4, 5, 2, 3}},
+		{TraversalPreOrder, 6, []int{6}},
+		// Pruning has no effect in post-order.
+		{TraversalPostOrder, 4, []int{0, 1, 4, 2, 3, 5, 6}},
+	}
+	for _, test := range tests {
+		var got []int
+		dend.Walk(test.order, func(node TreeNode) bool {
+			got = append(got, node.Label)
+			if node.IsLeaf() != (node.Label < 4) {
+				t.Fatalf("node %d: unexpected IsLeaf %v\n", node.Label, node.IsLeaf())
+			}
+			return node.Label != test.prune
+		})
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Fatalf("order %d, prune %d: expected %v, but got %v\n",
+				test.order, test.prune, test.expected, got)
+		}
+	}
+
+	empty := Linkage64([]float64{}, 0, MethodAverage)
+	empty.Walk(TraversalPreOrder, func(node TreeNode) bool {
+		t.Fatalf("expected no nodes, but got %+v\n", node)
+		return true
+	})
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic for invalid traversal order")
+		}
+	}()
+	dend.Walk(TraversalOrder(3), func(TreeNode) bool { return true })
+}
+
+func TestLeafOrderForest(t *testing.T) {
+	got := maPartialDendrogram().LeafOrder()
+	expected := []int{0, 1, 3, 5, 2, 4}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, but got %v\n", expected, got)
+	}
+}
+
+func TestTreesForest(t *testing.T) {
+	dend := maPartialDendrogram()
+	var roots []int
+	for _, tree := range dend.Trees() {
+		roots = append(roots, tree.Label)
+	}
+	if expected := dend.Roots(); !reflect.DeepEqual(roots, expected) {
+		t.Fatalf("expected roots %v, but got %v\n", expected, roots)
+	}
+
+	var labels []int
+	dend.Walk(TraversalLevelOrder, func(node TreeNode) bool {
+		labels = append(labels, node.Label)
+		return true
+	})
+	expected := []int{0, 1, 3, 7, 5, 6, 2, 4}
+	if !reflect.DeepEqual(labels, expected) {
+		t.Fatalf("expected %v, but got %v\n", expected, labels)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic for Tree of a forest\n")
+		}
+	}()
+	dend.Tree()
+}
+
+func TestOptimalLeafOrderForest(t *testing.T) {
+	dend := maPartialDendrogram()
+	got, err := dend.OptimalLeafOrder(maCondensedMatrix64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The singleton trees come first, followed by the tree {5, 2, 4}.
+	if len(got) != maObservations || !reflect.DeepEqual(got[:3], []int{0, 1, 3}) {
+		t.Fatalf("expected an order starting with [0 1 3], but got %v\n", got)
+	}
+	last := append([]int{}, got[3:]...)
+	sort.Ints(last)
+	if !reflect.DeepEqual(last, []int{2, 4, 5}) {
+		t.Fatalf("expected the last tree to be {2, 4, 5}, but got %v\n", got[3:])
+	}
+
+	dis := append([]float64{}, maCondensedMatrix64...)
+	empty, err := Linkage64Until(dis, maObservations, MethodAverage, func(Step) bool {
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = empty.OptimalLeafOrder(maCondensedMatrix64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []int{0, 1, 2, 3, 4, 5}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, but got %v\n", expected, got)
+	}
+}
@@ -0,0 +1,66 @@
+package kodama
+
+// unionFind is a simple disjoint-set over cluster labels.
+//
+// For a dendrogram with N observations, there are N + N - 1 possible cluster
+// labels. Labels less than N refer to observations, while a label N + i
+// refers to the cluster created by the ith step.
+type unionFind struct {
+	// A map from cluster label to its cluster's parent. When a cluster
+	// label is mapped to itself, then it is considered a root.
+	parents []int
+}
+
+// newUnionFind creates a new set where each of the given number of labels
+// starts out in its own singleton cluster.
+func newUnionFind(size int) *unionFind {
+	parents := make([]int, size)
+	for i := range parents {
+		parents[i] = i
+	}
+	return &unionFind{parents: parents}
+}
+
+// union merges the cluster containing child into the cluster containing
+// parent. If they are already in the same cluster, then this is a no-op.
+func (u *unionFind) union(child, parent int) {
+	child, parent = u.find(child), u.find(parent)
+	if child != parent {
+		u.parents[child] = parent
+	}
+}
+
+// find returns the root label of the cluster containing the given label.
+func (u *unionFind) find(label int) int {
+	root := label
+	for u.parents[root] != root {
+		root = u.parents[root]
+	}
+	// To speed up subsequent calls to find, point every label on the
+	// path directly at the root.
+	for label != root {
+		next := u.parents[label]
+		u.parents[label] = root
+		label = next
+	}
+	return root
+}
+
+// labels returns a flat cluster label for each of the given number of
+// observations, where observations share a label if and only if they are in
+// the same cluster. Labels are contiguous, start at 0 and are assigned in
+// order of each cluster's smallest observation.
+func (u *unionFind) labels(observations int) []int {
+	labels := make([]int, observations)
+	roots := make(map[int]int)
+	for i := range labels {
+		root := u.find(i)
+		label, ok := roots[root]
+		if !ok {
+			label = len(roots)
+			roots[root] = label
+		}
+		labels[i] = label
+	}
+	return labels
+}
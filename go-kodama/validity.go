@@ -0,0 +1,271 @@
+package kodama
+
+import (
+	"fmt"
+	"math"
+)
+
+// Silhouette returns the mean silhouette coefficient of the given flat
+// clustering, along with the silhouette coefficient of each observation.
+//
+// The condensed matrix should contain the original pairwise dissimilarities
+// between the observations, and labels[i] is the cluster label of the ith
+// observation, e.g., as returned by FlatClustersByCount. Labels may be any
+// integers.
+//
+// The silhouette of an observation is (b - a) / max(a, b), where a is its
+// mean dissimilarity to the other members of its cluster and b is the
+// smallest mean dissimilarity to the members of any other cluster. It
+// ranges from -1 to 1, where higher values indicate an observation is well
+// matched to its own cluster. By convention, the silhouette of an
+// observation in a singleton cluster is 0.
+//
+// An error is returned if the length of labels is not observations, if the
+// length of the condensed matrix is not observations-choose-2 or if there
+// are fewer than two clusters.
+func Silhouette(
+	condensed []float64,
+	observations int,
+	labels []int,
+) (mean float64, perPoint []float64, err error) {
+	if err := checkMatrixLen(len(condensed), observations); err != nil {
+		return 0, nil, err
+	}
+	if err := checkLabelsLen(len(labels), observations); err != nil {
+		return 0, nil, err
+	}
+	clusters, k := normalizeLabels(labels)
+	if k < 2 {
+		return 0, nil, fmt.Errorf(
+			"silhouette requires at least 2 clusters, but got %d", k)
+	}
+	sizes := make([]int, k)
+	for _, c := range clusters {
+		sizes[c]++
+	}
+
+	// sums[i*k+c] is the sum of the dissimilarities between observation i
+	// and every member of cluster c.
+	sums := make([]float64, observations*k)
+	idx := 0
+	for i := 0; i < observations; i++ {
+		for j := i + 1; j < observations; j++ {
+			d := condensed[idx]
+			idx++
+			sums[i*k+clusters[j]] += d
+			sums[j*k+clusters[i]] += d
+		}
+	}
+
+	perPoint = make([]float64, observations)
+	for i, own := range clusters {
+		if sizes[own] == 1 {
+			continue
+		}
+		a := sums[i*k+own] / float64(sizes[own]-1)
+		b := math.Inf(1)
+		for c := 0; c < k; c++ {
+			if c != own {
+				b = math.Min(b, sums[i*k+c]/float64(sizes[c]))
+			}
+		}
+		if denom := math.Max(a, b); denom > 0 {
+			perPoint[i] = (b - a) / denom
+		}
+		mean += perPoint[i]
+	}
+	mean /= float64(observations)
+	return mean, perPoint, nil
+}
+
+// DunnIndex returns the Dunn index of the given flat clustering.
+//
+// The condensed matrix should contain the original pairwise dissimilarities
+// between the observations, and labels[i] is the cluster label of the ith
+// observation. Labels may be any integers.
+//
+// The Dunn index is the smallest dissimilarity between two observations in
+// different clusters, divided by the largest dissimilarity between two
+// observations in the same cluster (i.e., the largest cluster diameter).
+// Higher values indicate compact, well separated clusters. If every cluster
+// has a diameter of 0, e.g., because every cluster is a singleton, then
+// +Inf is returned.
+//
+// An error is returned for all of the same reasons as Silhouette.
+func DunnIndex(condensed []float64, observations int, labels []int) (float64, error) {
+	if err := checkMatrixLen(len(condensed), observations); err != nil {
+		return 0, err
+	}
+	if err := checkLabelsLen(len(labels), observations); err != nil {
+		return 0, err
+	}
+	if _, k := normalizeLabels(labels); k < 2 {
+		return 0, fmt.Errorf(
+			"Dunn index requires at least 2 clusters, but got %d", k)
+	}
+	separation, diameter := math.Inf(1), 0.0
+	idx := 0
+	for i := 0; i < observations; i++ {
+		for j := i + 1; j < observations; j++ {
+			d := condensed[idx]
+			idx++
+			if labels[i] == labels[j] {
+				diameter = math.Max(diameter, d)
+			} else {
+				separation = math.Min(separation, d)
+			}
+		}
+	}
+	if diameter == 0 {
+		return math.Inf(1), nil
+	}
+	return separation / diameter, nil
+}
+
+// DaviesBouldin returns the Davies-Bouldin index of the given flat
+// clustering of feature vectors.
+//
+// The observations are given as a row-major n x dim matrix, as in
+// CondensedMatrix, and labels[i] is the cluster label of the ith
+// observation. Labels may be any integers.
+//
+// The scatter of a cluster is the mean Euclidean distance from its members
+// to its centroid. For each cluster, the worst ratio of the sum of its
+// scatter and another cluster's scatter to the distance between their
+// centroids is found, and the index is the mean of these ratios over all
+// clusters. Lower values indicate compact, well separated clusters, and the
+// minimum is 0. If two distinct clusters have the same centroid, then +Inf
+// is returned.
+//
+// An error is returned if the length of data is not n*dim, if the length of
+// labels is not n or if there are fewer than two clusters.
+func DaviesBouldin(data []float64, n, dim int, labels []int) (float64, error) {
+	if err := checkDataLen(len(data), n, dim); err != nil {
+		return 0, err
+	}
+	if err := checkLabelsLen(len(labels), n); err != nil {
+		return 0, err
+	}
+	clusters, k := normalizeLabels(labels)
+	if k < 2 {
+		return 0, fmt.Errorf(
+			"Davies-Bouldin index requires at least 2 clusters, but got %d", k)
+	}
+	centroids, err := ClusterCentroids(data, n, dim, clusters)
+	if err != nil {
+		return 0, err
+	}
+	scatter := make([]float64, k)
+	sizes := make([]int, k)
+	for i, c := range clusters {
+		scatter[c] += math.Sqrt(squaredEuclidean(data[i*dim:(i+1)*dim], centroids[c]))
+		sizes[c]++
+	}
+	for c := range scatter {
+		scatter[c] /= float64(sizes[c])
+	}
+
+	sum := 0.0
+	for a := 0; a < k; a++ {
+		worst := 0.0
+		for b := 0; b < k; b++ {
+			if a == b {
+				continue
+			}
+			separation := math.Sqrt(squaredEuclidean(centroids[a], centroids[b]))
+			if separation == 0 {
+				return math.Inf(1), nil
+			}
+			worst = math.Max(worst, (scatter[a]+scatter[b])/separation)
+		}
+		sum += worst
+	}
+	return sum / float64(k), nil
+}
+
+// checkLabelsLen returns an error if the given number of flat cluster
+// labels is not consistent with the number of observations.
+func checkLabelsLen(labelsLen, observations int) error {
+	if labelsLen != observations {
+		return fmt.Errorf(
+			"expected %d labels, but got %d", observations, labelsLen)
+	}
+	return nil
+}
+
+// normalizeLabels maps arbitrary flat cluster labels to contiguous labels
+// starting at 0, in order of first appearance. It also returns the number
+// of distinct clusters.
+func normalizeLabels(labels []int) ([]int, int) {
+	ids := make(map[int]int)
+	normalized := make([]int, len(labels))
+	for i, label := range labels {
+		id, ok := ids[label]
+		if !ok {
+			id = len(ids)
+			ids[label] = id
+		}
+		normalized[i] = id
+	}
+	return normalized, len(ids)
+}
+
+// FowlkesMallows returns the Fowlkes-Mallows index between two flat
+// clusterings of the same observations, e.g., a clustering from
+// FlatClusters and a set of ground truth labels. Labels may be any
+// integers, and the two clusterings need not use the same labels.
+//
+// The index is the geometric mean of the pairwise precision and recall,
+// where a pair of observations is counted as positive when both are in the
+// same cluster. It ranges from 0 to 1, where 1 means the clusterings are
+// identical up to relabeling. If no pair of observations is in the same
+// cluster in both clusterings, then 0 is returned.
+//
+// An error is returned if the clusterings do not have the same length.
+func FowlkesMallows(labelsA, labelsB []int) (float64, error) {
+	if err := checkSameLen(len(labelsA), len(labelsB)); err != nil {
+		return 0, err
+	}
+	return countPairs(labelsA, labelsB).fowlkesMallows(), nil
+}
+
+// AdjustedRandIndex returns the adjusted Rand index between two flat
+// clusterings of the same observations. Labels may be any integers, and the
+// two clusterings need not use the same labels.
+//
+// The Rand index is the fraction of pairs of observations on which the
+// clusterings agree, i.e., the pair is in the same cluster in both or in
+// different clusters in both. The adjusted Rand index corrects it for
+// chance, so that it is close to 0 for random clusterings (and may be
+// negative) and exactly 1 when the clusterings are identical up to
+// relabeling. When the index is undefined because both clusterings are
+// trivial in the same way (e.g., both put every observation in its own
+// cluster, or there are fewer than two observations), 1 is returned.
+//
+// An error is returned if the clusterings do not have the same length.
+func AdjustedRandIndex(labelsA, labelsB []int) (float64, error) {
+	if err := checkSameLen(len(labelsA), len(labelsB)); err != nil {
+		return 0, err
+	}
+	c := countPairs(labelsA, labelsB)
+	if c.total == 0 {
+		return 1, nil
+	}
+	expected := c.inA * c.inB / c.total
+	maximum := (c.inA + c.inB) / 2
+	if maximum == expected {
+		return 1, nil
+	}
+	return (c.inBoth - expected) / (maximum - expected), nil
+}
+
+// checkSameLen returns an error if two flat clusterings being compared do
+// not have the same length.
+func checkSameLen(lenA, lenB int) error {
+	if lenA != lenB {
+		return fmt.Errorf(
+			"expected clusterings of the same length, but got %d and %d",
+			lenA, lenB)
+	}
+	return nil
+}
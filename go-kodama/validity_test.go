@@ -0,0 +1,177 @@
+package kodama
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSilhouette(t *testing.T) {
+	labels := maDendrogram().FlatClustersByCount(3)
+	mean, perPoint, err := Silhouette(maCondensedMatrix64, maObservations, labels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Computed independently from maCondensedMatrix64.
+	expected := []float64{
+		0, 0.45188771302208436, 0.5888141676616514,
+		0, 0.6173731556978433, 0.12954842402683286,
+	}
+	assertFloatsApproxEq(t, perPoint, expected)
+	if math.Abs(mean-0.29793724340140204) > 0.000001 {
+		t.Fatalf("expected mean 0.297937, but got %f\n", mean)
+	}
+}
+
+func TestSilhouetteInvalid(t *testing.T) {
+	labels := []int{0, 0, 1, 1, 2, 2}
+	if _, _, err := Silhouette(maCondensedMatrix64[1:], maObservations, labels); err == nil {
+		t.Fatal("expected error for mismatched matrix length")
+	}
+	if _, _, err := Silhouette(maCondensedMatrix64, maObservations, labels[1:]); err == nil {
+		t.Fatal("expected error for mismatched labels length")
+	}
+	single := []int{7, 7, 7, 7, 7, 7}
+	if _, _, err := Silhouette(maCondensedMatrix64, maObservations, single); err == nil {
+		t.Fatal("expected error for a single cluster")
+	}
+}
+
+func TestDunnIndex(t *testing.T) {
+	// The points 0, 1, 5 and 7 on a line.
+	condensed := []float64{1, 5, 7, 4, 6, 2}
+	tests := []struct {
+		labels   []int
+		expected float64
+	}{
+		{[]int{3, 3, -1, -1}, 4.0 / 2},
+		{[]int{0, 1, 1, 1}, 1.0 / 6},
+		{[]int{0, 1, 2, 3}, math.Inf(1)},
+	}
+	for _, test := range tests {
+		got, err := DunnIndex(condensed, 4, test.labels)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.expected {
+			t.Fatalf("labels %v: expected %v, but got %v\n", test.labels, test.expected, got)
+		}
+	}
+
+	if _, err := DunnIndex(condensed[1:], 4, []int{0, 0, 1, 1}); err == nil {
+		t.Fatal("expected error for mismatched matrix length")
+	}
+	if _, err := DunnIndex(condensed, 4, []int{0, 0, 1}); err == nil {
+		t.Fatal("expected error for mismatched labels length")
+	}
+	if _, err := DunnIndex(condensed, 4, []int{5, 5, 5, 5}); err == nil {
+		t.Fatal("expected error for a single cluster")
+	}
+}
+
+func TestDaviesBouldin(t *testing.T) {
+	// The first cluster has centroid 1 and scatter 1, the second has
+	// centroid 12 and scatter 2, so both ratios are 3 / 11.
+	data := []float64{0, 2, 10, 14}
+	got, err := DaviesBouldin(data, 4, 1, []int{7, 7, -2, -2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(got-3.0/11) > 1e-12 {
+		t.Fatalf("expected %v, but got %v\n", 3.0/11, got)
+	}
+
+	// In two dimensions, with a third singleton cluster. The scatters are
+	// 1, 1 and 0, and the centroids are (0, 0), (10, 0) and (0, 5).
+	data = []float64{
+		-1, 0, 1, 0,
+		10, -1, 10, 1,
+		0, 5,
+	}
+	got, err = DaviesBouldin(data, 5, 2, []int{0, 0, 1, 1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	worst0 := math.Max(2.0/10, 1.0/5)
+	worst1 := math.Max(2.0/10, 1/math.Sqrt(125))
+	worst2 := math.Max(1.0/5, 1/math.Sqrt(125))
+	expected := (worst0 + worst1 + worst2) / 3
+	if math.Abs(got-expected) > 1e-12 {
+		t.Fatalf("expected %v, but got %v\n", expected, got)
+	}
+
+	got, err = DaviesBouldin([]float64{-1, 1, 0}, 3, 1, []int{0, 0, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsInf(got, 1) {
+		t.Fatalf("expected +Inf for clusters with the same centroid, but got %v\n", got)
+	}
+
+	if _, err := DaviesBouldin(data[1:], 5, 2, []int{0, 0, 1, 1, 2}); err == nil {
+		t.Fatal("expected error for mismatched data length")
+	}
+	if _, err := DaviesBouldin(data, 5, 2, []int{0, 0, 1, 1}); err == nil {
+		t.Fatal("expected error for mismatched labels length")
+	}
+	if _, err := DaviesBouldin(data, 5, 2, []int{1, 1, 1, 1, 1}); err == nil {
+		t.Fatal("expected error for a single cluster")
+	}
+}
+
+func TestFowlkesMallows(t *testing.T) {
+	tests := []struct {
+		a, b     []int
+		expected float64
+	}{
+		{[]int{0, 0, 1, 1}, []int{0, 0, 1, 1}, 1},
+		// Identical up to relabeling.
+		{[]int{0, 0, 1, 1}, []int{7, 7, -2, -2}, 1},
+		{[]int{0, 0, 0, 0}, []int{0, 1, 2, 3}, 0},
+		// 2 pairs agree, out of 6 pairs together in a and 3 pairs in b.
+		{[]int{0, 0, 0, 1, 1, 1}, []int{0, 0, 1, 1, 2, 2}, 2 / math.Sqrt(18)},
+		{[]int{}, []int{}, 0},
+	}
+	for _, test := range tests {
+		got, err := FowlkesMallows(test.a, test.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(got-test.expected) > 1e-12 {
+			t.Fatalf("%v vs %v: expected %v, but got %v\n",
+				test.a, test.b, test.expected, got)
+		}
+	}
+	if _, err := FowlkesMallows([]int{0, 1}, []int{0}); err == nil {
+		t.Fatalf("expected error for mismatched lengths\n")
+	}
+}
+
+func TestAdjustedRandIndex(t *testing.T) {
+	tests := []struct {
+		a, b     []int
+		expected float64
+	}{
+		{[]int{0, 0, 1, 1}, []int{0, 0, 1, 1}, 1},
+		{[]int{0, 0, 1, 1}, []int{1, 1, 0, 0}, 1},
+		{[]int{0, 0, 1, 2}, []int{0, 0, 1, 1}, 4.0 / 7.0},
+		{[]int{0, 0, 1, 1}, []int{0, 0, 1, 2}, 4.0 / 7.0},
+		{[]int{0, 0, 1, 1}, []int{0, 1, 0, 1}, -0.5},
+		{[]int{0, 0, 0, 0}, []int{0, 1, 2, 3}, 0},
+		{[]int{0, 1, 2, 3}, []int{3, 2, 1, 0}, 1},
+		{[]int{5}, []int{6}, 1},
+		{[]int{}, []int{}, 1},
+	}
+	for _, test := range tests {
+		got, err := AdjustedRandIndex(test.a, test.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(got-test.expected) > 1e-12 {
+			t.Fatalf("%v vs %v: expected %v, but got %v\n",
+				test.a, test.b, test.expected, got)
+		}
+	}
+	if _, err := AdjustedRandIndex([]int{0, 1}, []int{0}); err == nil {
+		t.Fatalf("expected error for mismatched lengths\n")
+	}
+}